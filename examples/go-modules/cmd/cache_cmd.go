@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var clearTask string
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect or clear the task cache",
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:          "clear",
+	Short:        "Delete cache entries",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ts, err := loadDefaultTaskFile()
+		if err != nil {
+			return err
+		}
+		removed, err := ts.Cache().Remove(ts.Resolve(clearTask))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Removed %d cache %s\n", removed, plural(removed, "entry", "entries"))
+		return nil
+	},
+}
+
+var cacheInfoCmd = &cobra.Command{
+	Use:          "info",
+	Short:        "Show the cache location, size and entry count",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ts, err := loadDefaultTaskFile()
+		if err != nil {
+			return err
+		}
+		cache := ts.Cache()
+		entries, err := cache.Entries()
+		if err != nil {
+			return err
+		}
+		size, err := cache.Size()
+		if err != nil {
+			return err
+		}
+		w := cmd.OutOrStdout()
+		fmt.Fprintf(w, "Directory: %s\n", cache.Dir())
+		fmt.Fprintf(w, "Entries:   %d\n", len(entries))
+		fmt.Fprintf(w, "Size:      %s\n", formatBytes(size))
+		return nil
+	},
+}
+
+func init() {
+	cacheClearCmd.Flags().StringVar(&clearTask, "task", "", "Only delete entries of this task")
+	cacheClearCmd.RegisterFlagCompletionFunc("task", completeTaskNames)
+	cacheCmd.AddCommand(cacheClearCmd)
+	cacheCmd.AddCommand(cacheInfoCmd)
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package cmd
+
+import "os"
+
+// enableVT is a no-op outside Windows, where terminals understand ANSI.
+func enableVT(*os.File) bool {
+	return true
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Shell completion scripts come from cobra's built-in `completion` command;
+// this file supplies the dynamic part.
+
+// completeTaskNames completes task names, with their descriptions, for
+// commands that take tasks as arguments, followed by their aliases. Tasks
+// already given, by name or alias, are omitted.
+func completeTaskNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	ts, err := loadDefaultTaskFile()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	given := make([]string, len(args))
+	for i, arg := range args {
+		given[i] = ts.Resolve(arg)
+	}
+	var names []string
+	add := func(name, desc string) {
+		if strings.HasPrefix(name, toComplete) && !slices.Contains(given, ts.Resolve(name)) {
+			if desc != "" {
+				name += "\t" + desc
+			}
+			names = append(names, name)
+		}
+	}
+	for _, name := range ts.Names() {
+		add(name, ts.Tasks[name].Description)
+	}
+	targets := ts.Aliases()
+	aliases := make([]string, 0, len(targets))
+	for alias := range targets {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		add(alias, "alias for "+targets[alias])
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
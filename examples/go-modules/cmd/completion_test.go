@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompleteTaskNames(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  build: {description: Build the binary}
+  bench: {}
+  test: {}
+`)
+	chdir(t, ts.Dir())
+
+	names, directive := completeTaskNames(runCmd, nil, "b")
+	assert.Equal(t, []string{"bench", "build\tBuild the binary"}, names)
+	assert.Equal(t, cobra.ShellCompDirectiveNoFileComp, directive)
+
+	names, _ = completeTaskNames(runCmd, []string{"bench"}, "b")
+	assert.Equal(t, []string{"build\tBuild the binary"}, names)
+}
+
+func TestCompleteTaskAliases(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  build: {aliases: [b]}
+  test: {aliases: [t]}
+`)
+	chdir(t, ts.Dir())
+
+	names, _ := completeTaskNames(runCmd, nil, "")
+	assert.Equal(t, []string{"build", "test", "b\talias for build", "t\talias for test"}, names)
+
+	names, _ = completeTaskNames(runCmd, []string{"b"}, "")
+	assert.Equal(t, []string{"test", "t\talias for test"}, names)
+}
+
+func TestCompletionScripts(t *testing.T) {
+	// cobra captures the output writer when it builds the completion
+	// command, so drop any earlier one and reuse one buffer for every shell.
+	if c, _, err := rootCmd.Find([]string{"completion"}); err == nil && c != rootCmd {
+		rootCmd.RemoveCommand(c)
+	}
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	for _, shell := range []string{"bash", "zsh", "fish", "powershell"} {
+		out.Reset()
+		rootCmd.SetArgs([]string{"completion", shell})
+		require.NoError(t, rootCmd.Execute(), shell)
+		assert.Contains(t, out.String(), "gocli", shell)
+	}
+	rootCmd.SetArgs(nil)
+	rootCmd.SetOut(nil)
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/example/gocli/zr"
+)
+
+// assumeYes is set by the --yes flag.
+var assumeYes bool
+
+// confirmTask asks on the terminal whether to run a task with a confirm
+// question. Without a terminal to ask on, only --yes lets the task run.
+func confirmTask(t *zr.Task) (bool, error) {
+	if assumeYes {
+		return true, nil
+	}
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return false, fmt.Errorf("task %q needs confirmation: %s (pass --yes to run it without a terminal)", t.Name, t.Confirm)
+	}
+	return promptYes(os.Stdin, newPrinter(os.Stdout), t.Confirm)
+}
+
+// promptYes writes question followed by [y/N] and reports whether the
+// answer read from in is yes. Anything else, including no answer at all,
+// is no.
+func promptYes(in io.Reader, out io.Writer, question string) (bool, error) {
+	fmt.Fprintf(out, "%s %s ", colorize(colorYellow, question), colorize(colorDim, "[y/N]"))
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	if err == io.EOF {
+		fmt.Fprintln(out)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/example/gocli/zr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptYes(t *testing.T) {
+	for answer, want := range map[string]bool{
+		"y\n": true, "YES\n": true, " yes \n": true,
+		"n\n": false, "\n": false, "yess\n": false, "": false,
+	} {
+		var out bytes.Buffer
+		ok, err := promptYes(strings.NewReader(answer), &out, "Deploy to production?")
+		require.NoError(t, err)
+		assert.Equal(t, want, ok, "answer %q", answer)
+		assert.True(t, strings.HasPrefix(sgrPattern.ReplaceAllString(out.String(), ""), "Deploy to production? [y/N] "))
+	}
+}
+
+func TestConfirmTaskWithoutTerminal(t *testing.T) {
+	task := &zr.Task{Name: "deploy", Confirm: "Deploy to production?"}
+	_, err := confirmTask(task)
+	assert.EqualError(t, err, `task "deploy" needs confirmation: Deploy to production? (pass --yes to run it without a terminal)`)
+
+	assumeYes = true
+	t.Cleanup(func() { assumeYes = false })
+	ok, err := confirmTask(task)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
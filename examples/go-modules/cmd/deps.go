@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/example/gocli/zr"
+	"github.com/spf13/cobra"
+)
+
+var depsCmd = &cobra.Command{
+	Use:   "deps <task>",
+	Short: "List every task a task depends on, directly or not",
+	Long: `List the transitive dependencies of a task: every task that running it
+would run first, once each and in execution order. Direct dependencies are
+marked, so the list shows what a change to a shared task can reach.`,
+	Args:              cobra.ExactArgs(1),
+	SilenceUsage:      true,
+	ValidArgsFunction: completeTaskNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ts, err := loadDefaultTaskFile()
+		if err != nil {
+			return err
+		}
+		t, err := ts.Lookup(args[0])
+		if err != nil {
+			return err
+		}
+		deps, err := ts.Closure(t.Name)
+		if err != nil {
+			return err
+		}
+		printClosure(cmd.OutOrStdout(), t, deps)
+		return nil
+	},
+}
+
+// printClosure writes the dependencies of t one per line, marking the ones
+// t names itself.
+func printClosure(w io.Writer, t *zr.Task, deps []*zr.Task) {
+	if len(deps) == 0 {
+		fmt.Fprintf(w, "%s has no dependencies\n", colorize(colorCyan, t.Name))
+		return
+	}
+	direct := map[string]bool{}
+	for _, d := range t.Deps {
+		direct[d] = true
+	}
+	for _, d := range deps {
+		line := d.Name
+		if direct[d.Name] {
+			line += colorize(colorDim, " (direct)")
+		}
+		fmt.Fprintln(w, line)
+	}
+}
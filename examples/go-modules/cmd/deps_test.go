@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/example/gocli/zr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintClosure(t *testing.T) {
+	gen := &zr.Task{Name: "gen"}
+	build := &zr.Task{Name: "build", Deps: []string{"gen"}}
+	test := &zr.Task{Name: "test", Deps: []string{"build"}}
+
+	var out bytes.Buffer
+	printClosure(&out, test, []*zr.Task{gen, build})
+	assert.Equal(t, "gen\nbuild (direct)\n", sgrPattern.ReplaceAllString(out.String(), ""))
+
+	out.Reset()
+	printClosure(&out, gen, nil)
+	assert.Equal(t, "gen has no dependencies\n", sgrPattern.ReplaceAllString(out.String(), ""))
+}
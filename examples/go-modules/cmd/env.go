@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+)
+
+// envDefaults maps environment variables to the flags they provide a
+// default for. A flag given on the command line wins over its variable,
+// which wins over the flag's built-in default.
+var envDefaults = []struct {
+	env  string
+	flag string
+}{
+	{"ZR_FILE", "file"},
+	{"ZR_JOBS", "jobs"},
+	{"ZR_NO_CACHE", "no-cache"},
+	{"ZR_REMOTE_CACHE", "remote-cache"},
+	{"ZR_LOG", "log-level"},
+}
+
+// applyEnvDefaults sets the flags that weren't given on the command line
+// from their environment variables, ignoring empty ones. Flags the command
+// doesn't have are left alone.
+func applyEnvDefaults(flags *pflag.FlagSet) error {
+	for _, d := range envDefaults {
+		v := os.Getenv(d.env)
+		f := flags.Lookup(d.flag)
+		if v == "" || f == nil || f.Changed {
+			continue
+		}
+		if err := f.Value.Set(v); err != nil {
+			return fmt.Errorf("%s: invalid value %q for --%s: %w", d.env, v, d.flag, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyEnvDefaults(t *testing.T) {
+	newFlags := func() (*pflag.FlagSet, *string, *int, *bool) {
+		flags := pflag.NewFlagSet("run", pflag.ContinueOnError)
+		return flags, flags.String("file", "", ""), flags.Int("jobs", 4, ""), flags.Bool("no-cache", false, "")
+	}
+	t.Setenv("ZR_FILE", "ci/zr.yaml")
+	t.Setenv("ZR_JOBS", "2")
+	t.Setenv("ZR_NO_CACHE", "")
+
+	flags, file, jobs, noCache := newFlags()
+	require.NoError(t, flags.Parse(nil))
+	require.NoError(t, applyEnvDefaults(flags))
+	assert.Equal(t, "ci/zr.yaml", *file)
+	assert.Equal(t, 2, *jobs)
+	assert.False(t, *noCache, "empty variables are ignored")
+
+	t.Setenv("ZR_NO_CACHE", "1")
+	flags, file, jobs, noCache = newFlags()
+	require.NoError(t, flags.Parse([]string{"--jobs", "8", "--file", "zr.yaml"}))
+	require.NoError(t, applyEnvDefaults(flags))
+	assert.Equal(t, "zr.yaml", *file, "flags win over the environment")
+	assert.Equal(t, 8, *jobs)
+	assert.True(t, *noCache)
+
+	t.Setenv("ZR_JOBS", "many")
+	flags, _, _, _ = newFlags()
+	require.NoError(t, flags.Parse(nil))
+	assert.ErrorContains(t, applyEnvDefaults(flags), `ZR_JOBS: invalid value "many" for --jobs`)
+
+	// Commands without the flag ignore its variable.
+	require.NoError(t, applyEnvDefaults(pflag.NewFlagSet("list", pflag.ContinueOnError)))
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/example/gocli/zr"
+)
+
+// fuzzyMatch reports whether the runes of query appear in s in order,
+// ignoring case. The score rewards matches that are consecutive or start a
+// word and penalizes the gaps between them, so "bld" ranks "build" above
+// "rebuild-docs". Of all the ways query can match, the best-scoring one is
+// picked; positions holds the rune indexes of s it matched.
+func fuzzyMatch(query, s string) (score int, positions []int, ok bool) {
+	q := []rune(strings.ToLower(query))
+	if len(q) == 0 {
+		return 0, nil, true
+	}
+	target := []rune(strings.ToLower(s))
+	bonus := func(j int) int {
+		if j == 0 || strings.ContainsRune(" -_:./", target[j-1]) {
+			return 4
+		}
+		return 1
+	}
+	// best[i][j] is the top score of matching q[:i+1] with q[i] at target[j],
+	// or unmatched; from[i][j] is where q[i-1] went on that path.
+	const unmatched = -1 << 30
+	best := make([][]int, len(q))
+	from := make([][]int, len(q))
+	for i := range q {
+		best[i] = make([]int, len(target))
+		from[i] = make([]int, len(target))
+		for j, r := range target {
+			best[i][j] = unmatched
+			if r != q[i] {
+				continue
+			}
+			if i == 0 {
+				best[i][j] = bonus(j)
+				continue
+			}
+			for k := i - 1; k < j; k++ {
+				if best[i-1][k] == unmatched {
+					continue
+				}
+				step := -min(j-k-1, 3)
+				if j == k+1 {
+					step = 5
+				}
+				if v := best[i-1][k] + step + bonus(j); v > best[i][j] {
+					best[i][j], from[i][j] = v, k
+				}
+			}
+		}
+	}
+	last := len(q) - 1
+	end := -1
+	for j := range target {
+		if best[last][j] != unmatched && (end < 0 || best[last][j] > best[last][end]) {
+			end = j
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+	positions = make([]int, len(q))
+	for i, j := last, end; i >= 0; i-- {
+		positions[i] = j
+		j = from[i][j]
+	}
+	return best[last][end], positions, true
+}
+
+// taskMatch is a task that matched a filter query, with the matched rune
+// positions of whichever of its name or description scored better.
+type taskMatch struct {
+	task    *zr.Task
+	score   int
+	namePos []int
+	descPos []int
+}
+
+// filterTasks returns the tasks whose name or description fuzzy-matches
+// query, best match first and ties broken by name. An empty query matches
+// every task.
+func filterTasks(tasks []*zr.Task, query string) []taskMatch {
+	var matches []taskMatch
+	for _, t := range tasks {
+		nameScore, namePos, nameOK := fuzzyMatch(query, t.Name)
+		descScore, descPos, descOK := fuzzyMatch(query, t.Description)
+		switch {
+		case nameOK && (!descOK || nameScore >= descScore):
+			matches = append(matches, taskMatch{task: t, score: nameScore, namePos: namePos})
+		case descOK:
+			matches = append(matches, taskMatch{task: t, score: descScore, descPos: descPos})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].task.Name < matches[j].task.Name
+	})
+	return matches
+}
+
+// highlight colors the runes of s at positions.
+func highlight(s string, positions []int, color string) string {
+	if len(positions) == 0 {
+		return s
+	}
+	var b strings.Builder
+	next := 0
+	for i, r := range []rune(s) {
+		if next < len(positions) && positions[next] == i {
+			b.WriteString(colorize(color, string(r)))
+			next++
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
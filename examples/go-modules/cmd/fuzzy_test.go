@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/example/gocli/zr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	_, pos, ok := fuzzyMatch("bld", "Build")
+	assert.True(t, ok)
+	assert.Equal(t, []int{0, 3, 4}, pos)
+
+	_, _, ok = fuzzyMatch("dlb", "build")
+	assert.False(t, ok, "characters must appear in order")
+
+	_, pos, ok = fuzzyMatch("", "build")
+	assert.True(t, ok)
+	assert.Empty(t, pos)
+}
+
+func TestFuzzyMatchRanksConsecutiveAndWordStarts(t *testing.T) {
+	prefix, _, _ := fuzzyMatch("test", "test-unit")
+	scattered, _, _ := fuzzyMatch("test", "the-best")
+	assert.Greater(t, prefix, scattered)
+
+	wordStart, _, _ := fuzzyMatch("d", "build-docs")
+	inner, _, _ := fuzzyMatch("d", "build")
+	assert.Greater(t, wordStart, inner)
+}
+
+func TestFilterTasks(t *testing.T) {
+	tasks := []*zr.Task{
+		{Name: "build", Description: "Compile the binary"},
+		{Name: "lint", Description: "Run the linters"},
+		{Name: "rebuild-docs"},
+	}
+	var names []string
+	for _, m := range filterTasks(tasks, "bld") {
+		names = append(names, m.task.Name)
+	}
+	assert.Equal(t, []string{"build", "rebuild-docs"}, names)
+
+	matches := filterTasks(tasks, "linters")
+	if assert.Len(t, matches, 1) {
+		assert.Equal(t, "lint", matches[0].task.Name)
+		assert.Empty(t, matches[0].namePos)
+		assert.Len(t, matches[0].descPos, len("linters"))
+	}
+
+	assert.Len(t, filterTasks(tasks, ""), 3)
+}
+
+func TestHighlight(t *testing.T) {
+	assert.Equal(t, "b"+colorize(colorYellow, "u")+"ild", highlight("build", []int{1}, colorYellow))
+	assert.Equal(t, "build", highlight("build", nil, colorYellow))
+}
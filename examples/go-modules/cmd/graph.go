@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/example/gocli/zr"
+	"github.com/spf13/cobra"
+)
+
+var graphFormat string
+
+var graphCmd = &cobra.Command{
+	Use:               "graph [task]...",
+	Short:             "Show the task dependency graph as execution levels",
+	SilenceUsage:      true,
+	ValidArgsFunction: completeTaskNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if graphFormat != "text" && graphFormat != "dot" {
+			return fmt.Errorf("unknown graph format %q (want text or dot)", graphFormat)
+		}
+		ts, err := loadDefaultTaskFile()
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			args = ts.Names()
+		}
+		plan, err := ts.Plan(args)
+		if err != nil {
+			return err
+		}
+		if graphFormat == "dot" {
+			printDOT(cmd.OutOrStdout(), plan)
+			return nil
+		}
+		printLevels(cmd.OutOrStdout(), plan, false)
+		return nil
+	},
+}
+
+func init() {
+	graphCmd.Flags().StringVar(&graphFormat, "format", "text", "Output format: text or dot")
+	graphCmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"text", "dot"}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// levelFills are the Graphviz fill colors nodes get by execution level.
+var levelFills = []string{"#d0e6ff", "#d5f5d5", "#fff3c4", "#ffd9cc", "#ead9ff"}
+
+// printDOT writes the plan as a Graphviz digraph. Every task is a node,
+// filled by execution level, with an edge from each dependency to the task
+// that needs it, and a dashed one from each planned task another only runs
+// after.
+func printDOT(w io.Writer, plan zr.Plan) {
+	planned := map[string]bool{}
+	fmt.Fprintln(w, "digraph tasks {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	fmt.Fprintln(w, `  node [shape=box, style="rounded,filled"];`)
+	for i, tasks := range plan {
+		for _, t := range tasks {
+			fmt.Fprintf(w, "  %s [fillcolor=%q, tooltip=%q];\n",
+				strconv.Quote(t.Name), levelFills[i%len(levelFills)], fmt.Sprintf("level %d", i))
+			planned[t.Name] = true
+		}
+	}
+	for _, tasks := range plan {
+		for _, t := range tasks {
+			for _, dep := range t.Deps {
+				fmt.Fprintf(w, "  %s -> %s;\n", strconv.Quote(dep), strconv.Quote(t.Name))
+			}
+			for _, prev := range t.RunAfter {
+				if planned[prev] {
+					fmt.Fprintf(w, "  %s -> %s [style=dashed];\n", strconv.Quote(prev), strconv.Quote(t.Name))
+				}
+			}
+		}
+	}
+	fmt.Fprintln(w, "}")
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintDOT(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  build: {}
+  test: {deps: [build]}
+  lonely: {}
+  docs: {run_after: [test, deploy]}
+  deploy: {deps: [test]}
+`)
+	levels, err := ts.Plan([]string{"docs", "lonely", "test"})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	printDOT(&out, levels)
+	assert.Equal(t, `digraph tasks {
+  rankdir=LR;
+  node [shape=box, style="rounded,filled"];
+  "build" [fillcolor="#d0e6ff", tooltip="level 0"];
+  "lonely" [fillcolor="#d0e6ff", tooltip="level 0"];
+  "test" [fillcolor="#d5f5d5", tooltip="level 1"];
+  "docs" [fillcolor="#fff3c4", tooltip="level 2"];
+  "build" -> "test";
+  "test" -> "docs" [style=dashed];
+}
+`, out.String())
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/example/gocli/zr"
+	"github.com/spf13/cobra"
+)
+
+var initForce bool
+
+// projectType holds the starter commands for a kind of project.
+type projectType struct {
+	Name   string
+	Marker string
+	Build  string
+	Test   string
+	Clean  string
+	Inputs []string
+}
+
+// projectTypes are checked in order; the first whose marker file exists wins.
+var projectTypes = []projectType{
+	{Name: "Go", Marker: "go.mod", Build: "go build ./...", Test: "go test ./...", Clean: "go clean ./...", Inputs: []string{"**/*.go", "go.mod", "go.sum"}},
+	{Name: "Node.js", Marker: "package.json", Build: "npm run build", Test: "npm test", Clean: "rm -rf dist", Inputs: []string{"src/", "package.json"}},
+	{Name: "Rust", Marker: "Cargo.toml", Build: "cargo build", Test: "cargo test", Clean: "cargo clean", Inputs: []string{"src/", "Cargo.toml"}},
+}
+
+var genericProject = projectType{
+	Build: `echo "replace with your build command"`,
+	Test:  `echo "replace with your test command"`,
+	Clean: `echo "replace with your clean command"`,
+}
+
+// detectProject picks the project type of dir from its marker files.
+func detectProject(dir string) projectType {
+	for _, p := range projectTypes {
+		if _, err := os.Stat(filepath.Join(dir, p.Marker)); err == nil {
+			return p
+		}
+	}
+	return genericProject
+}
+
+var starterTemplate = template.Must(template.New("starter").Parse(`# Task file for zr.
+{{- if .Name}}
+# Detected: {{.Name}} ({{.Marker}} found)
+{{- end}}
+#
+# Run a task with "zr run <task>"; its deps run first. See every task with
+# "zr list" and how they depend on each other with "zr graph".
+
+tasks:
+  build:
+    description: Build the project
+    cmd: {{printf "%q" .Build}}
+{{- if .Inputs}}
+    # Skip the build when no input changed since the outputs were written.
+    # inputs:{{range .Inputs}}
+    #   - {{printf "%q" .}}{{end}}
+    # outputs:
+    #   - bin/
+{{- end}}
+
+  test:
+    description: Run the tests
+    cmd: {{printf "%q" .Test}}
+    deps: [build]
+
+  clean:
+    description: Remove build artifacts
+    cmd: {{printf "%q" .Clean}}
+`))
+
+// writeStarterTaskFile writes a commented starter task file for project to
+// path. An existing file is only replaced when force is set.
+func writeStarterTaskFile(path string, project projectType, force bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%s already exists (use --force to overwrite)", path)
+	}
+	if err != nil {
+		return err
+	}
+	if err := starterTemplate.Execute(f, project); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+var initCmd = &cobra.Command{
+	Use:          "init",
+	Short:        "Create a starter task file in the current directory",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		project := detectProject(dir)
+		path := filepath.Join(dir, zr.DefaultFileName)
+		if err := writeStarterTaskFile(path, project, initForce); err != nil {
+			return err
+		}
+		kind := "generic"
+		if project.Name != "" {
+			kind = project.Name
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Created %s for a %s project\n", zr.DefaultFileName, kind)
+		return nil
+	},
+}
+
+func init() {
+	initCmd.Flags().BoolVar(&initForce, "force", false, "Overwrite an existing task file")
+}
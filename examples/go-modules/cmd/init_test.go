@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/example/gocli/zr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectProject(t *testing.T) {
+	assert.Equal(t, "Go", detectProject(writeFiles(t, map[string]string{"go.mod": "module x"})).Name)
+	assert.Equal(t, "Node.js", detectProject(writeFiles(t, map[string]string{"package.json": "{}"})).Name)
+	assert.Equal(t, "Rust", detectProject(writeFiles(t, map[string]string{"Cargo.toml": ""})).Name)
+	assert.Empty(t, detectProject(t.TempDir()).Name)
+}
+
+func TestWriteStarterTaskFile(t *testing.T) {
+	for _, project := range append(projectTypes, genericProject) {
+		path := filepath.Join(t.TempDir(), zr.DefaultFileName)
+		require.NoError(t, writeStarterTaskFile(path, project, false))
+
+		ts, err := zr.LoadTasks(path)
+		require.NoError(t, err, project.Name)
+		assert.Equal(t, []string{"build", "clean", "test"}, ts.Names())
+		assert.Equal(t, zr.CommandList{project.Build}, ts.Tasks["build"].Cmd)
+		assert.Equal(t, []string{"build"}, ts.Tasks["test"].Deps)
+
+		err = writeStarterTaskFile(path, project, false)
+		assert.ErrorContains(t, err, "already exists (use --force to overwrite)")
+		require.NoError(t, os.WriteFile(path, []byte("junk"), 0o644))
+		require.NoError(t, writeStarterTaskFile(path, project, true))
+		_, err = zr.LoadTasks(path)
+		assert.NoError(t, err)
+	}
+}
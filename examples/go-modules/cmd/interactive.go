@@ -0,0 +1,252 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/example/gocli/zr"
+	"github.com/spf13/cobra"
+)
+
+var interactiveCmd = &cobra.Command{
+	Use:     "interactive",
+	Aliases: []string{"i"},
+	Short:   "Pick tasks to run from a filterable list",
+	Long: `Pick tasks to run from a filterable list.
+
+Typing narrows the list to tasks whose name or description fuzzy-matches
+the query, i.e. contains its characters in order. The arrow keys (or
+Ctrl-P/Ctrl-N) move through the matches and Space selects or deselects the
+task under the cursor; selections survive changing the query. Enter runs
+the selected tasks, or the one under the cursor if none are selected,
+together with their dependencies in a single run, so a task shared by
+several of them runs once. Esc or Ctrl-C quits without running anything.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ts, err := loadDefaultTaskFile()
+		if err != nil {
+			return err
+		}
+		if !isTerminal(os.Stdin) {
+			return errors.New("interactive mode needs a terminal")
+		}
+		tasks := make([]*zr.Task, 0, len(ts.Tasks))
+		for _, name := range ts.Names() {
+			tasks = append(tasks, ts.Tasks[name])
+		}
+		restore, err := makeRaw(os.Stdin)
+		if err != nil {
+			return err
+		}
+		picked, err := runPicker(bufio.NewReader(os.Stdin), cmd.OutOrStdout(), newPicker(tasks))
+		restore()
+		if err != nil || len(picked) == 0 {
+			return err
+		}
+		names := make([]string, len(picked))
+		for i, t := range picked {
+			names[i] = t.Name
+		}
+		plan, err := ts.Plan(names)
+		if err != nil {
+			return err
+		}
+		return executePlan(cmd, ts, plan)
+	},
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+type keyKind int
+
+const (
+	keyRune keyKind = iota
+	keyUp
+	keyDown
+	keyEnter
+	keyToggle
+	keyBackspace
+	keyCancel
+	keyUnknown
+)
+
+type keyPress struct {
+	kind keyKind
+	r    rune
+}
+
+// readKey reads one key press from a terminal in raw mode. A lone Esc
+// cancels; one followed by more buffered input starts an escape sequence,
+// of which only the up and down arrows are understood.
+func readKey(r *bufio.Reader) (keyPress, error) {
+	c, _, err := r.ReadRune()
+	if err != nil {
+		return keyPress{}, err
+	}
+	switch c {
+	case '\r', '\n':
+		return keyPress{kind: keyEnter}, nil
+	case ' ':
+		return keyPress{kind: keyToggle}, nil
+	case 0x7f, '\b':
+		return keyPress{kind: keyBackspace}, nil
+	case 0x03, 0x04: // Ctrl-C, Ctrl-D
+		return keyPress{kind: keyCancel}, nil
+	case 0x10: // Ctrl-P
+		return keyPress{kind: keyUp}, nil
+	case 0x0e: // Ctrl-N
+		return keyPress{kind: keyDown}, nil
+	case 0x1b:
+		if r.Buffered() == 0 {
+			return keyPress{kind: keyCancel}, nil
+		}
+		if b, _ := r.ReadByte(); b != '[' && b != 'O' {
+			return keyPress{kind: keyUnknown}, nil
+		}
+		switch b, _ := r.ReadByte(); b {
+		case 'A':
+			return keyPress{kind: keyUp}, nil
+		case 'B':
+			return keyPress{kind: keyDown}, nil
+		}
+		return keyPress{kind: keyUnknown}, nil
+	}
+	if !unicode.IsPrint(c) {
+		return keyPress{kind: keyUnknown}, nil
+	}
+	return keyPress{kind: keyRune, r: c}, nil
+}
+
+// pickerRows is how many matches the picker shows at once.
+const pickerRows = 15
+
+// picker is the state of the interactive task list. selected holds the
+// tasks toggled with Space, in the order they were toggled on.
+type picker struct {
+	tasks    []*zr.Task
+	query    []rune
+	matches  []taskMatch
+	cursor   int
+	selected []*zr.Task
+}
+
+func newPicker(tasks []*zr.Task) *picker {
+	p := &picker{tasks: tasks}
+	p.refilter()
+	return p
+}
+
+func (p *picker) refilter() {
+	p.matches = filterTasks(p.tasks, string(p.query))
+	p.cursor = 0
+}
+
+// handle applies k. It reports whether picking is over, along with the
+// chosen tasks, which are nil when the picker was cancelled.
+func (p *picker) handle(k keyPress) (done bool, picked []*zr.Task) {
+	switch k.kind {
+	case keyRune:
+		p.query = append(p.query, k.r)
+		p.refilter()
+	case keyBackspace:
+		if len(p.query) > 0 {
+			p.query = p.query[:len(p.query)-1]
+			p.refilter()
+		}
+	case keyUp:
+		if p.cursor > 0 {
+			p.cursor--
+		}
+	case keyDown:
+		if p.cursor < len(p.matches)-1 {
+			p.cursor++
+		}
+	case keyToggle:
+		if len(p.matches) > 0 {
+			p.toggle(p.matches[p.cursor].task)
+		}
+	case keyEnter:
+		if len(p.selected) > 0 {
+			return true, p.selected
+		}
+		if len(p.matches) > 0 {
+			return true, []*zr.Task{p.matches[p.cursor].task}
+		}
+	case keyCancel:
+		return true, nil
+	}
+	return false, nil
+}
+
+// toggle selects t, or deselects it if it is already selected.
+func (p *picker) toggle(t *zr.Task) {
+	if i := slices.Index(p.selected, t); i >= 0 {
+		p.selected = slices.Delete(p.selected, i, i+1)
+		return
+	}
+	p.selected = append(p.selected, t)
+}
+
+// render draws the picker from the top of the screen. Lines end in \r\n
+// because the terminal is in raw mode.
+func (p *picker) render(w io.Writer) {
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	count := fmt.Sprintf("%d/%d", len(p.matches), len(p.tasks))
+	if len(p.selected) > 0 {
+		count += fmt.Sprintf(", %d selected", len(p.selected))
+	}
+	fmt.Fprintf(&b, "%s %s  %s\r\n", colorize(colorBold, ">"), string(p.query), colorize(colorDim, count))
+	width := 0
+	for _, m := range p.matches {
+		width = max(width, len([]rune(m.task.Name)))
+	}
+	start := max(0, p.cursor-pickerRows+1)
+	end := min(len(p.matches), start+pickerRows)
+	for i := start; i < end; i++ {
+		m := p.matches[i]
+		marker := "  "
+		if i == p.cursor {
+			marker = colorize(colorCyan, "› ")
+		}
+		if slices.Contains(p.selected, m.task) {
+			marker += colorize(colorGreen, "● ")
+		} else {
+			marker += "  "
+		}
+		pad := strings.Repeat(" ", width-len([]rune(m.task.Name)))
+		fmt.Fprintf(&b, "%s%s%s  %s\r\n", marker, highlight(m.task.Name, m.namePos, colorYellow), pad,
+			highlight(m.task.Description, m.descPos, colorYellow))
+	}
+	b.WriteString(colorize(colorDim, "↑/↓ move  space select  enter run  esc quit"))
+	io.WriteString(w, b.String())
+}
+
+// runPicker redraws p after every key read from in until tasks are chosen
+// or picking is cancelled, then clears the screen.
+func runPicker(in *bufio.Reader, out io.Writer, p *picker) ([]*zr.Task, error) {
+	defer io.WriteString(out, "\x1b[H\x1b[2J")
+	for {
+		p.render(out)
+		k, err := readKey(in)
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if done, picked := p.handle(k); done {
+			return picked, nil
+		}
+	}
+}
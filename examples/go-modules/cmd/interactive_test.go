@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/example/gocli/zr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadKey(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("a \x1b[B\x1b[A\x7f\r\x03"))
+	want := []keyPress{
+		{kind: keyRune, r: 'a'},
+		{kind: keyToggle},
+		{kind: keyDown},
+		{kind: keyUp},
+		{kind: keyBackspace},
+		{kind: keyEnter},
+		{kind: keyCancel},
+	}
+	for _, w := range want {
+		k, err := readKey(r)
+		require.NoError(t, err)
+		assert.Equal(t, w, k)
+	}
+}
+
+func pickerTasks() []*zr.Task {
+	return []*zr.Task{
+		{Name: "build", Description: "Compile"},
+		{Name: "bench"},
+		{Name: "lint"},
+	}
+}
+
+func TestPickerFiltersAndNavigates(t *testing.T) {
+	var out bytes.Buffer
+	// "b" keeps bench and build; down moves to the second match.
+	in := bufio.NewReader(strings.NewReader("b\x1b[B\r"))
+	picked, err := runPicker(in, &out, newPicker(pickerTasks()))
+	require.NoError(t, err)
+	require.Len(t, picked, 1)
+	assert.Equal(t, "build", picked[0].Name)
+	assert.Contains(t, out.String(), "2/3")
+}
+
+func TestPickerMultiSelect(t *testing.T) {
+	var out bytes.Buffer
+	// Select lint, then bench and build under the "b" filter, then
+	// deselect bench again.
+	in := bufio.NewReader(strings.NewReader("\x1b[B\x1b[B b \x1b[B \x1b[A \r"))
+	picked, err := runPicker(in, &out, newPicker(pickerTasks()))
+	require.NoError(t, err)
+	var names []string
+	for _, t := range picked {
+		names = append(names, t.Name)
+	}
+	assert.Equal(t, []string{"lint", "build"}, names)
+	assert.Contains(t, out.String(), "2/3, 3 selected")
+}
+
+func TestPickerBackspaceWidensFilter(t *testing.T) {
+	p := newPicker(pickerTasks())
+	p.handle(keyPress{kind: keyRune, r: 'l'})
+	p.handle(keyPress{kind: keyRune, r: 'i'})
+	assert.Len(t, p.matches, 1)
+	p.handle(keyPress{kind: keyBackspace})
+	p.handle(keyPress{kind: keyBackspace})
+	assert.Len(t, p.matches, 3)
+}
+
+func TestPickerCancel(t *testing.T) {
+	var out bytes.Buffer
+	picked, err := runPicker(bufio.NewReader(strings.NewReader("zzz\r\x1b")), &out, newPicker(pickerTasks()))
+	require.NoError(t, err)
+	assert.Nil(t, picked, "enter with no matches does nothing, esc cancels")
+}
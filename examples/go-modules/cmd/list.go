@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"encoding/json"
+
+	"github.com/example/gocli/zr"
+	"github.com/spf13/cobra"
+)
+
+var listJSON bool
+
+// taskInfo is the serializable form of a task's metadata.
+type taskInfo struct {
+	Name         string   `json:"name"`
+	Description  string   `json:"description"`
+	Group        string   `json:"group"`
+	Dependencies []string `json:"dependencies"`
+	Cacheable    bool     `json:"cacheable"`
+}
+
+func newTaskInfo(t *zr.Task) taskInfo {
+	deps := t.Deps
+	if deps == nil {
+		deps = []string{}
+	}
+	return taskInfo{
+		Name:         t.Name,
+		Description:  t.Description,
+		Group:        t.Group,
+		Dependencies: deps,
+		Cacheable:    t.Cacheable(),
+	}
+}
+
+var listCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "List available tasks",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ts, err := loadDefaultTaskFile()
+		if err != nil {
+			return err
+		}
+		w := cmd.OutOrStdout()
+		if listJSON {
+			infos := []taskInfo{}
+			for _, name := range ts.Names() {
+				infos = append(infos, newTaskInfo(ts.Tasks[name]))
+			}
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(infos)
+		}
+		tasks := make([]*zr.Task, 0, len(ts.Tasks))
+		for _, name := range ts.Names() {
+			tasks = append(tasks, ts.Tasks[name])
+		}
+		printTaskList(w, tasks)
+		return nil
+	},
+}
+
+func init() {
+	listCmd.Flags().BoolVar(&listJSON, "json", false, "Print tasks as JSON")
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/example/gocli/zr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListJSON(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  build:
+    description: Build the binary
+    cmd: go build
+    inputs: ["*.go"]
+    outputs: [bin/app]
+  test:
+    cmd: go test ./...
+    deps: [build]
+`)
+	chdir(t, filepath.Dir(ts.Path))
+
+	var out bytes.Buffer
+	listCmd.SetOut(&out)
+	listJSON = true
+	t.Cleanup(func() { listJSON = false })
+	require.NoError(t, listCmd.RunE(listCmd, nil))
+
+	var infos []taskInfo
+	require.NoError(t, json.Unmarshal(out.Bytes(), &infos))
+	assert.Equal(t, []taskInfo{
+		{Name: "build", Description: "Build the binary", Dependencies: []string{}, Cacheable: true},
+		{Name: "test", Dependencies: []string{"build"}},
+	}, infos)
+	assert.NotContains(t, out.String(), "\x1b[")
+}
+
+func TestPrintTaskListAligned(t *testing.T) {
+	var out bytes.Buffer
+	printTaskList(&out, []*zr.Task{
+		{Name: "build", Description: "Build the binary"},
+		{Name: "fmt"},
+		{Name: "generate", Description: "Run go generate"},
+	})
+	assert.Equal(t, ""+
+		"build     Build the binary\n"+
+		"fmt\n"+
+		"generate  Run go generate\n", sgrPattern.ReplaceAllString(out.String(), ""))
+}
+
+func TestPrintTaskListGrouped(t *testing.T) {
+	var out bytes.Buffer
+	printTaskList(&out, []*zr.Task{
+		{Name: "build", Group: "build", Description: "Build the binary"},
+		{Name: "clean", Description: "Remove artifacts"},
+		{Name: "lint", Group: "test", Description: "Run linters"},
+		{Name: "test-race", Group: "test", Description: "Test with -race"},
+	})
+	assert.Equal(t, ""+
+		"build:\n"+
+		"  build      Build the binary\n"+
+		"\n"+
+		"test:\n"+
+		"  lint       Run linters\n"+
+		"  test-race  Test with -race\n"+
+		"\n"+
+		"Other:\n"+
+		"  clean      Remove artifacts\n", sgrPattern.ReplaceAllString(out.String(), ""))
+}
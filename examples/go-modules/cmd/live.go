@@ -0,0 +1,309 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/example/gocli/internal/glob"
+	"github.com/example/gocli/zr"
+	"github.com/spf13/cobra"
+)
+
+var (
+	debounceWindow time.Duration
+	liveClear      bool
+)
+
+var liveCmd = &cobra.Command{
+	Use:   "live <task>...",
+	Short: "Run tasks, then re-run them whenever files change",
+	Long: `Run tasks, then re-run them whenever files in the task file's directory change.
+
+A task with watch patterns is only re-run when a matching file changes;
+tasks without them are re-run on any change.
+
+File events arriving within the --debounce window of each other are merged
+into a single re-run. If files change while a run is still in progress, that
+run is cancelled and a fresh one starts once the changes settle.
+
+Editing the task file, or a file it includes, reloads it and re-runs every
+task with the new definitions. If the edited file doesn't load, the old
+definitions stay in use until it is fixed.
+
+With --clear each run starts on a cleared screen under a header naming the
+changed files and the time, and ends with a line saying whether it passed.
+When stdout isn't a terminal the screen is left alone and the headers are
+appended to the output instead.`,
+	Args:              cobra.MinimumNArgs(1),
+	SilenceUsage:      true,
+	ValidArgsFunction: completeTaskNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ts, err := loadDefaultTaskFile()
+		if err != nil {
+			return err
+		}
+		opts := liveOptions{debounce: debounceWindow, summary: liveClear, clear: liveClear && isTerminal(os.Stdout)}
+		return runLive(cmd.Context(), cmd.OutOrStdout(), cmd.ErrOrStderr(), ts, args, opts)
+	},
+}
+
+func init() {
+	liveCmd.Flags().DurationVar(&debounceWindow, "debounce", 200*time.Millisecond,
+		"How long file changes must settle before re-running")
+	liveCmd.Flags().BoolVar(&liveClear, "clear", false,
+		"Clear the screen and print a status header before every run")
+}
+
+// liveOptions configures runLive.
+type liveOptions struct {
+	// debounce is how long file changes must settle before a re-run.
+	debounce time.Duration
+	// summary frames every run with a header and a closing status line.
+	summary bool
+	// clear wipes the screen before each header.
+	clear bool
+}
+
+// runLive runs names once and then, after every settled batch of file
+// changes, re-runs those of them the changes are relevant to, until ctx is
+// done. A run still in progress when changes arrive is cancelled and its
+// tasks are included in the next one.
+func runLive(ctx context.Context, stdout, stderr io.Writer, ts *zr.TaskSet, names []string, opts liveOptions) error {
+	plan, err := ts.Plan(names)
+	if err != nil {
+		return err
+	}
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	var targets []liveTarget
+	var changes <-chan []string
+	stopWatching := func() {}
+	// watch starts watching for the current ts and plan, replacing the
+	// watcher of the task set they were reloaded from.
+	watch := func() error {
+		w, err := newWatcher(ts.Dir(), ts.Files(), outputsOf(plan))
+		if err != nil {
+			return err
+		}
+		stopWatching()
+		watchCtx, cancel := context.WithCancel(ctx)
+		stopWatching = cancel
+		changes = debounce(watchCtx, w.watch(watchCtx, pollInterval), opts.debounce)
+		targets = make([]liveTarget, len(names))
+		for i, name := range names {
+			targets[i] = newLiveTarget(ts.Tasks[ts.Resolve(name)])
+		}
+		return nil
+	}
+	if err := watch(); err != nil {
+		return err
+	}
+
+	cancel := func() {}
+	done := make(chan struct{})
+	var running []string
+	start := func(names []string, trigger string) {
+		runCtx, c := context.WithCancel(ctx)
+		cancel = c
+		d := make(chan struct{})
+		done = d
+		running = names
+		if opts.summary {
+			printLiveHeader(stdout, opts.clear, trigger, names, time.Now())
+		} else if trigger != "" {
+			fmt.Fprintln(stdout, colorize(colorYellow, "changed: "+trigger))
+		}
+		go func() {
+			defer close(d)
+			began := time.Now()
+			_, err := ts.Run(runCtx, names, zr.RunOptions{
+				Stdout: stdout, Stderr: stderr, Verbose: verbose, Confirm: confirmTask,
+			})
+			if runCtx.Err() != nil {
+				return
+			}
+			if err != nil {
+				fmt.Fprintf(stderr, "%s %v\n", colorize(colorRed, "Error:"), err)
+			}
+			if opts.summary {
+				printLiveStatus(stdout, err, time.Since(began))
+			}
+		}()
+	}
+
+	start(names, "")
+	for {
+		select {
+		case <-ctx.Done():
+			cancel()
+			<-done
+			return nil
+		case changed, ok := <-changes:
+			if !ok {
+				cancel()
+				<-done
+				return nil
+			}
+			rels := relPaths(ts.Dir(), changed)
+			if touchesTaskFile(ts, changed) {
+				reloaded, reloadedPlan, err := reloadTaskFile(ts, names)
+				if err != nil {
+					fmt.Fprintf(stderr, "%s reloading task file: %v (keeping the old one)\n", colorize(colorRed, "Error:"), err)
+				} else {
+					cancel()
+					<-done
+					ts, plan = reloaded, reloadedPlan
+					if err := watch(); err != nil {
+						return err
+					}
+					fmt.Fprintln(stdout, colorize(colorYellow, "reloaded task file"))
+					start(names, describeChanges(rels))
+					continue
+				}
+			}
+			var next []string
+			select {
+			case <-done:
+			default:
+				// Still running: restart with what was interrupted.
+				next = append(next, running...)
+			}
+			for _, t := range targets {
+				if t.triggeredBy(rels) && !slices.Contains(next, t.task.Name) {
+					next = append(next, t.task.Name)
+				}
+			}
+			if len(next) == 0 {
+				continue
+			}
+			cancel()
+			<-done
+			start(next, describeChanges(rels))
+		}
+	}
+}
+
+// touchesTaskFile reports whether any of the changed paths is one of the
+// files ts was loaded from.
+func touchesTaskFile(ts *zr.TaskSet, changed []string) bool {
+	files := ts.Files()
+	for _, path := range changed {
+		if slices.Contains(files, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// reloadTaskFile loads ts again from its file and plans names with it,
+// so a task file that no longer loads, or no longer has the tasks live is
+// running, is rejected before anything is swapped.
+func reloadTaskFile(ts *zr.TaskSet, names []string) (*zr.TaskSet, zr.Plan, error) {
+	reloaded, err := zr.LoadTasks(ts.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	plan, err := reloaded.Plan(names)
+	if err != nil {
+		return nil, nil, err
+	}
+	return reloaded, plan, nil
+}
+
+// printLiveHeader starts a live run triggered by changes to the files in
+// trigger, or the initial run when trigger is empty, optionally on a
+// cleared screen.
+func printLiveHeader(w io.Writer, clear bool, trigger string, names []string, at time.Time) {
+	if clear {
+		io.WriteString(w, "\x1b[H\x1b[2J\x1b[3J")
+	}
+	if trigger == "" {
+		trigger = "initial run"
+	} else {
+		trigger = "changed: " + trigger
+	}
+	fmt.Fprintf(w, "%s %s\n", colorize(colorBold, "zr live"), colorize(colorDim, at.Format("15:04:05")))
+	fmt.Fprintln(w, colorize(colorYellow, trigger))
+	fmt.Fprintln(w, colorize(colorDim, "running: "+strings.Join(names, ", ")))
+	fmt.Fprintln(w)
+}
+
+// printLiveStatus ends a live run that wasn't cancelled.
+func printLiveStatus(w io.Writer, err error, took time.Duration) {
+	fmt.Fprintln(w)
+	if err != nil {
+		fmt.Fprintln(w, colorize(colorRed, "✗ failed")+colorize(colorDim, " after "+formatDuration(took)+", waiting for changes"))
+		return
+	}
+	fmt.Fprintln(w, colorize(colorGreen, "✓ passed")+colorize(colorDim, " in "+formatDuration(took)+", waiting for changes"))
+}
+
+// liveTarget is a task live mode re-runs, with the paths that trigger it.
+type liveTarget struct {
+	task *zr.Task
+	// watch is nil for tasks without watch patterns, which any change
+	// triggers.
+	watch glob.Set
+}
+
+func newLiveTarget(t *zr.Task) liveTarget {
+	lt := liveTarget{task: t}
+	if len(t.Watch) > 0 {
+		lt.watch = glob.CompileSet(t.Watch)
+	}
+	return lt
+}
+
+// triggeredBy reports whether any of the slash-separated relative paths is
+// relevant to the target.
+func (lt liveTarget) triggeredBy(rels []string) bool {
+	if lt.watch == nil {
+		return len(rels) > 0
+	}
+	for _, rel := range rels {
+		if lt.watch.Matches(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// outputsOf returns an ignore function for the outputs of the planned tasks,
+// so a task writing its own outputs doesn't re-trigger itself.
+func outputsOf(plan zr.Plan) func(rel string) bool {
+	var patterns []string
+	for _, tasks := range plan {
+		for _, t := range tasks {
+			patterns = append(patterns, t.Outputs...)
+		}
+	}
+	set := glob.CompileSet(patterns)
+	return set.Matches
+}
+
+// relPaths makes paths relative to root and slash-separated.
+func relPaths(root string, paths []string) []string {
+	rels := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if rel, err := filepath.Rel(root, p); err == nil {
+			p = rel
+		}
+		rels = append(rels, filepath.ToSlash(p))
+	}
+	return rels
+}
+
+func describeChanges(rels []string) string {
+	const max = 3
+	if len(rels) > max {
+		return strings.Join(rels[:max], ", ") + fmt.Sprintf(" and %d more", len(rels)-max)
+	}
+	return strings.Join(rels, ", ")
+}
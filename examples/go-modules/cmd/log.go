@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/example/gocli/zr"
+)
+
+var logLevel string
+
+// setupLogger sends zr's diagnostics at logLevel and above to w, which is
+// stderr, keeping them out of task output and of anything printed to
+// stdout such as list --json.
+func setupLogger(w io.Writer) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		return fmt.Errorf("invalid log level %q (want error, warn, info or debug)", logLevel)
+	}
+	zr.SetLogger(slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level})))
+	return nil
+}
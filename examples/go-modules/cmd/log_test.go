@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/example/gocli/zr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupLogger(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  build: {cmd: go build}
+`)
+	t.Cleanup(func() {
+		logLevel = "warn"
+		zr.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	})
+
+	var log bytes.Buffer
+	logLevel = "debug"
+	require.NoError(t, setupLogger(&log))
+	_, err := ts.Plan([]string{"build"})
+	require.NoError(t, err)
+	assert.Contains(t, log.String(), `level=DEBUG msg="planned level" level=0 tasks=[build]`)
+
+	log.Reset()
+	logLevel = "warn"
+	require.NoError(t, setupLogger(&log))
+	_, err = ts.Plan([]string{"build"})
+	require.NoError(t, err)
+	assert.Empty(t, log.String())
+
+	logLevel = "loud"
+	assert.EqualError(t, setupLogger(&log), `invalid log level "loud" (want error, warn, info or debug)`)
+}
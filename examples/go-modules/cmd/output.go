@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/example/gocli/zr"
+)
+
+var (
+	// noColor is set by the --no-color flag.
+	noColor bool
+	// verbose is set by the --verbose flag.
+	verbose bool
+)
+
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorDim    = "\x1b[2m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorCyan   = "\x1b[36m"
+)
+
+func colorize(color, s string) string {
+	return color + s + colorReset
+}
+
+// sgrPattern matches ANSI SGR (color and style) escape sequences.
+var sgrPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// isColorCapable reports whether f can render ANSI colors: color hasn't been
+// disabled with --no-color or NO_COLOR, f is a terminal, and on Windows the
+// console accepts VT mode, which legacy consoles don't.
+func isColorCapable(f *os.File) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	return enableVT(f)
+}
+
+// newPrinter returns the writer all user-facing output to f goes through.
+// If f can render colors it is returned as is, so subprocesses still see a
+// terminal; otherwise SGR sequences are stripped before writing.
+func newPrinter(f *os.File) io.Writer {
+	if isColorCapable(f) {
+		return f
+	}
+	return sgrStripper{w: f}
+}
+
+type sgrStripper struct {
+	w io.Writer
+}
+
+func (s sgrStripper) Write(p []byte) (int, error) {
+	if _, err := s.w.Write(sgrPattern.ReplaceAll(p, nil)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// printLevels writes an execution plan in the format shared by `graph` and
+// `run --dry-run`. With withCmds set, each task is followed by the command
+// it would run.
+func printLevels(w io.Writer, plan zr.Plan, withCmds bool) {
+	planned := map[string]bool{}
+	for _, tasks := range plan {
+		for _, t := range tasks {
+			planned[t.Name] = true
+		}
+	}
+	for i, tasks := range plan {
+		fmt.Fprintln(w, colorize(colorBold, fmt.Sprintf("Level %d:", i)))
+		for _, t := range tasks {
+			line := "  " + colorize(colorCyan, t.Name)
+			if len(t.Deps) > 0 {
+				line += colorize(colorDim, " <- "+strings.Join(t.Deps, ", "))
+			}
+			var after []string
+			for _, prev := range t.RunAfter {
+				if planned[prev] {
+					after = append(after, prev)
+				}
+			}
+			if len(after) > 0 {
+				line += colorize(colorDim, " (after "+strings.Join(after, ", ")+")")
+			}
+			fmt.Fprintln(w, line)
+			if withCmds {
+				for _, cmdLine := range t.Cmd.Lines() {
+					fmt.Fprintln(w, colorize(colorDim, "    $ "+cmdLine))
+				}
+			}
+		}
+	}
+}
+
+// ungrouped is the section heading of tasks without a group.
+const ungrouped = "Other"
+
+// printTaskList writes tasks, in the given order, with their descriptions
+// aligned in a column. If any task has a group, tasks are listed under
+// alphabetized group headings, with ungrouped tasks in a final section.
+func printTaskList(w io.Writer, tasks []*zr.Task) {
+	width := 0
+	sections := map[string][]*zr.Task{}
+	for _, t := range tasks {
+		width = max(width, len(t.Name))
+		sections[t.Group] = append(sections[t.Group], t)
+	}
+	groups := make([]string, 0, len(sections))
+	for g := range sections {
+		if g != "" {
+			groups = append(groups, g)
+		}
+	}
+	sort.Strings(groups)
+
+	printTasks := func(indent string, tasks []*zr.Task) {
+		for _, t := range tasks {
+			line := indent + colorize(colorCyan, t.Name)
+			if t.Description != "" {
+				line += strings.Repeat(" ", width-len(t.Name)+2) + t.Description
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+	if len(groups) == 0 {
+		printTasks("", tasks)
+		return
+	}
+	if len(sections[""]) > 0 {
+		groups = append(groups, "")
+	}
+	for i, g := range groups {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		heading := g
+		if g == "" {
+			heading = ungrouped
+		}
+		fmt.Fprintln(w, colorize(colorBold, heading+":"))
+		printTasks("  ", sections[g])
+	}
+}
+
+// printSummary lists the tasks of a run by outcome, in plan order.
+func printSummary(w io.Writer, res *zr.Result) {
+	groups := []struct {
+		label  string
+		color  string
+		status zr.Status
+	}{
+		{"succeeded", colorGreen, zr.StatusSucceeded},
+		{"failed", colorRed, zr.StatusFailed},
+		{"skipped", colorDim, zr.StatusSkipped},
+	}
+	fmt.Fprintln(w, colorize(colorBold, "Summary:"))
+	for _, g := range groups {
+		var names []string
+		for _, tr := range res.Tasks {
+			if tr.Status == g.status {
+				names = append(names, tr.Task.Name)
+			}
+		}
+		if len(names) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "  %s %s\n", colorize(g.color, fmt.Sprintf("%-10s", g.label+":")), strings.Join(names, ", "))
+	}
+}
+
+// printTimings lists how long each started task took, slowest first,
+// followed by the wall-clock time of the whole run. In parallel runs the
+// summed task and CPU times exceed the wall time by roughly the speedup.
+func printTimings(w io.Writer, res *zr.Result) {
+	var started []zr.TaskResult
+	for _, tr := range res.Tasks {
+		if tr.Started() {
+			started = append(started, tr)
+		}
+	}
+	sort.SliceStable(started, func(i, j int) bool { return started[i].Duration > started[j].Duration })
+	width := 0
+	for _, tr := range started {
+		width = max(width, len(tr.Task.Name))
+	}
+	var total, totalCPU time.Duration
+	fmt.Fprintln(w, colorize(colorBold, "Timings:"))
+	for _, tr := range started {
+		total += tr.Duration
+		totalCPU += tr.CPU
+		fmt.Fprintf(w, "  %-*s  %8s  %s\n", width, tr.Task.Name, formatDuration(tr.Duration),
+			colorize(colorDim, "cpu "+formatDuration(tr.CPU)))
+	}
+	fmt.Fprintf(w, "  %s wall, %s summed task time, %s cpu\n",
+		colorize(colorBold, formatDuration(res.Duration)), formatDuration(total), formatDuration(totalCPU))
+}
+
+// printLogs lists the log file of every task that wrote one.
+func printLogs(w io.Writer, res *zr.Result) {
+	width := 0
+	for _, tr := range res.Tasks {
+		if tr.LogFile != "" {
+			width = max(width, len(tr.Task.Name))
+		}
+	}
+	if width == 0 {
+		return
+	}
+	fmt.Fprintln(w, colorize(colorBold, "Logs:"))
+	for _, tr := range res.Tasks {
+		if tr.LogFile != "" {
+			fmt.Fprintf(w, "  %-*s  %s\n", width, tr.Task.Name, tr.LogFile)
+		}
+	}
+}
+
+// formatDuration rounds d to a precision that suits its magnitude.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Minute:
+		return d.Round(time.Second).String()
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond).String()
+	default:
+		return d.Round(time.Millisecond).String()
+	}
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// formatBytes renders n bytes with a binary unit, e.g. "1.5 KiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/example/gocli/zr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSGRStripper(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := sgrStripper{w: &buf}.Write([]byte(colorize(colorBold, "Level 0:") + " " + colorize(colorCyan, "build")))
+	require.NoError(t, err)
+	assert.Equal(t, len(colorize(colorBold, "Level 0:")+" "+colorize(colorCyan, "build")), n)
+	assert.Equal(t, "Level 0: build", buf.String())
+}
+
+func TestIsColorCapable(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	require.NoError(t, err)
+	defer f.Close()
+	assert.False(t, isColorCapable(f), "regular files are not terminals")
+	assert.IsType(t, sgrStripper{}, newPrinter(f))
+
+	t.Setenv("NO_COLOR", "1")
+	assert.False(t, isColorCapable(os.Stdout))
+}
+
+func TestPrintTimings(t *testing.T) {
+	res := &zr.Result{Duration: 2 * time.Second, Tasks: []zr.TaskResult{
+		{Task: &zr.Task{Name: "lint"}, Status: zr.StatusSucceeded, Duration: 300 * time.Millisecond},
+		{Task: &zr.Task{Name: "build"}, Status: zr.StatusSucceeded, Duration: 1500 * time.Millisecond, CPU: 2 * time.Second},
+		{Task: &zr.Task{Name: "test"}, Status: zr.StatusFailed, Duration: 900 * time.Millisecond, CPU: 800 * time.Millisecond},
+		{Task: &zr.Task{Name: "deploy"}, Status: zr.StatusSkipped},
+	}}
+	var out bytes.Buffer
+	printTimings(&out, res)
+	assert.Equal(t, `Timings:
+  build      1.5s  cpu 2s
+  test      900ms  cpu 800ms
+  lint      300ms  cpu 0s
+  2s wall, 2.7s summed task time, 2.8s cpu
+`, sgrPattern.ReplaceAllString(out.String(), ""))
+}
+
+func TestPrintSummary(t *testing.T) {
+	res := &zr.Result{Tasks: []zr.TaskResult{
+		{Task: &zr.Task{Name: "broken"}, Status: zr.StatusFailed},
+		{Task: &zr.Task{Name: "lint"}, Status: zr.StatusSucceeded},
+		{Task: &zr.Task{Name: "deploy"}, Status: zr.StatusSkipped},
+		{Task: &zr.Task{Name: "vet"}, Status: zr.StatusSucceeded},
+	}}
+	var out bytes.Buffer
+	printSummary(&out, res)
+	assert.Equal(t, `Summary:
+  succeeded: lint, vet
+  failed:    broken
+  skipped:   deploy
+`, sgrPattern.ReplaceAllString(out.String(), ""))
+}
+
+func TestPrintLogs(t *testing.T) {
+	res := &zr.Result{Tasks: []zr.TaskResult{
+		{Task: &zr.Task{Name: "build"}, LogFile: "logs/build.log"},
+		{Task: &zr.Task{Name: "all"}},
+		{Task: &zr.Task{Name: "test-race"}, LogFile: "logs/test-race.log"},
+	}}
+	var out bytes.Buffer
+	printLogs(&out, res)
+	assert.Equal(t, `Logs:
+  build      logs/build.log
+  test-race  logs/test-race.log
+`, sgrPattern.ReplaceAllString(out.String(), ""))
+
+	out.Reset()
+	printLogs(&out, &zr.Result{})
+	assert.Empty(t, out.String())
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512 B", formatBytes(512))
+	assert.Equal(t, "1.5 KiB", formatBytes(1536))
+	assert.Equal(t, "2.0 MiB", formatBytes(2<<20))
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+
+	"github.com/example/gocli/zr"
+)
+
+// runReport is the JSON document written by run --report.
+type runReport struct {
+	// Status is "succeeded" when every task succeeded, else "failed".
+	Status     string       `json:"status"`
+	DurationMS int64        `json:"duration_ms"`
+	Tasks      []taskReport `json:"tasks"`
+}
+
+// taskReport is the outcome of one planned task. ExitCode is only set for
+// tasks whose command ran to completion, and Cached for tasks skipped
+// because their outputs were up to date or their cache entry matched, not
+// for tasks skipped because they aren't for this system. Log is the file
+// the output went to with --log-dir.
+type taskReport struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	ExitCode   *int   `json:"exit_code"`
+	DurationMS int64  `json:"duration_ms"`
+	Cached     bool   `json:"cached"`
+	Error      string `json:"error,omitempty"`
+	Log        string `json:"log,omitempty"`
+}
+
+// newRunReport collects the outcome of every planned task, in plan order.
+func newRunReport(res *zr.Result) runReport {
+	report := runReport{Status: zr.StatusSucceeded.String(), DurationMS: res.Duration.Milliseconds(), Tasks: []taskReport{}}
+	for _, r := range res.Tasks {
+		tr := taskReport{Name: r.Task.Name, Status: r.Status.String(), Cached: r.SkipReason == "up to date" || r.SkipReason == "cached"}
+		if r.Started() {
+			tr.DurationMS = r.Duration.Milliseconds()
+			if r.SkipReason == "" && len(r.Task.Cmd) > 0 {
+				tr.ExitCode = exitCodeOf(r.Err)
+			}
+			if r.Err != nil {
+				tr.Error = r.Err.Error()
+			}
+			tr.Log = r.LogFile
+		}
+		if r.Status != zr.StatusSucceeded {
+			report.Status = zr.StatusFailed.String()
+		}
+		report.Tasks = append(report.Tasks, tr)
+	}
+	return report
+}
+
+// exitCodeOf returns the exit code of a finished command from the error it
+// returned, or nil if the command didn't exit on its own.
+func exitCodeOf(err error) *int {
+	code := 0
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr) && exitErr.ExitCode() >= 0:
+		code = exitErr.ExitCode()
+	default:
+		return nil
+	}
+	return &code
+}
+
+func (r runReport) write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
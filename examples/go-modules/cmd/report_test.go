@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWritesReport(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  gen: {cmd: touch out.txt, inputs: [in.txt], outputs: [out.txt]}
+  lint: {cmd: exit 3}
+  build: {cmd: "true", deps: [gen]}
+  test: {cmd: "true", deps: [lint]}
+`)
+	require.NoError(t, os.WriteFile(filepath.Join(ts.Dir(), "in.txt"), []byte("x"), 0o644))
+	chdir(t, ts.Dir())
+	runCmd.SetOut(&bytes.Buffer{})
+	runCmd.SetContext(context.Background())
+	jobs, continueOnError = 1, true
+	reportPath = filepath.Join(t.TempDir(), "report.json")
+	t.Cleanup(func() {
+		jobs, continueOnError, reportPath = runtime.NumCPU(), false, ""
+	})
+	require.Error(t, runCmd.RunE(runCmd, []string{"build", "test"}))
+
+	data, err := os.ReadFile(reportPath)
+	require.NoError(t, err)
+	var report runReport
+	require.NoError(t, json.Unmarshal(data, &report))
+	assert.Equal(t, "failed", report.Status)
+
+	byName := map[string]taskReport{}
+	for _, tr := range report.Tasks {
+		byName[tr.Name] = tr
+	}
+	require.Len(t, byName, 4)
+	assert.Equal(t, "succeeded", byName["build"].Status)
+	assert.Equal(t, 0, *byName["build"].ExitCode)
+	assert.Equal(t, "failed", byName["lint"].Status)
+	assert.Equal(t, 3, *byName["lint"].ExitCode)
+	assert.Equal(t, "exit status 3", byName["lint"].Error)
+	assert.Equal(t, "skipped", byName["test"].Status)
+	assert.Nil(t, byName["test"].ExitCode)
+
+	// A second run finds gen cached.
+	reportPath = filepath.Join(t.TempDir(), "again.json")
+	runCmd.RunE(runCmd, []string{"build", "test"})
+	data, err = os.ReadFile(reportPath)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &report))
+	for _, tr := range report.Tasks {
+		if tr.Name == "gen" {
+			assert.True(t, tr.Cached)
+			assert.Nil(t, tr.ExitCode)
+		}
+	}
+}
@@ -1,8 +1,12 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
 
+	"github.com/example/gocli/zr"
 	"github.com/spf13/cobra"
 )
 
@@ -10,17 +14,97 @@ var rootCmd = &cobra.Command{
 	Use:   "gocli",
 	Short: "A simple CLI application built with Go and Cobra",
 	Long: `gocli is a demonstration CLI application showing how to use
-zr for Go project task automation and orchestration.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("Hello from gocli! Use --help to see available commands.")
+zr for Go project task automation and orchestration.
+
+Environment variables provide defaults for flags. A flag given on the
+command line always wins over its variable, which wins over the built-in
+default:
+
+  ZR_FILE          --file
+  ZR_JOBS          --jobs
+  ZR_NO_CACHE      --no-cache
+  ZR_REMOTE_CACHE  --remote-cache
+  ZR_LOG           --log-level
+
+ZR_REMOTE_CACHE_TOKEN, if set, is sent as a bearer token to the remote
+cache.`,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyEnvDefaults(cmd.Flags()); err != nil {
+			return err
+		}
+		return setupLogger(cmd.ErrOrStderr())
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Like make without a target, run the task file's default task.
+		ts, err := loadDefaultTaskFile()
+		if errors.Is(err, zr.ErrNoTaskFile) {
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+		if ts != nil {
+			if name, ok := ts.DefaultTask(); ok {
+				plan, err := ts.Plan([]string{name})
+				if err != nil {
+					return err
+				}
+				return executePlan(cmd, ts, plan)
+			}
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), "Hello from gocli! Use --help to see available commands.")
+		return nil
 	},
 }
 
 func Execute() error {
-	return rootCmd.Execute()
+	ctx, interrupted, stop := handleInterrupts(context.Background())
+	defer stop()
+	err := rootCmd.ExecuteContext(ctx)
+	if interrupted() {
+		err = errInterrupted
+	}
+	if err != nil {
+		fmt.Fprintf(newPrinter(os.Stderr), "%s %v\n", colorize(colorRed, "Error:"), err)
+	}
+	return err
+}
+
+// setupOutput routes all command output through printers that strip colors
+// when the terminal can't render them. It runs once flags are parsed and
+// leaves writers that were set explicitly alone.
+func setupOutput() {
+	if rootCmd.OutOrStdout() == os.Stdout {
+		rootCmd.SetOut(newPrinter(os.Stdout))
+	}
+	if rootCmd.ErrOrStderr() == os.Stderr {
+		rootCmd.SetErr(newPrinter(os.Stderr))
+	}
 }
 
 func init() {
+	cobra.OnInitialize(setupOutput)
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable colored output")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false,
+		"Echo each command and its environment before running it")
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false,
+		"Run tasks that ask for confirmation without asking")
+	rootCmd.PersistentFlags().StringVarP(&taskFilePath, "file", "f", "",
+		"Task file to use instead of discovering "+zr.DefaultFileName)
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "warn",
+		"Log zr's own diagnostics at this level and above to stderr: error, warn, info or debug")
+
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(greetCmd)
+	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(graphCmd)
+	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(liveCmd)
+	rootCmd.AddCommand(initCmd)
+	rootCmd.AddCommand(interactiveCmd)
+	rootCmd.AddCommand(whyCmd)
+	rootCmd.AddCommand(depsCmd)
 }
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRootRunsDefaultTask(t *testing.T) {
+	cases := map[string]string{
+		"default key":  "default: build\ntasks:\n  build: {cmd: touch ran}\n",
+		"default task": "tasks:\n  default: {cmd: touch ran}\n",
+	}
+	for name, content := range cases {
+		ts := writeTaskFile(t, content)
+		chdir(t, ts.Dir())
+		var out bytes.Buffer
+		rootCmd.SetOut(&out)
+		rootCmd.SetContext(context.Background())
+		require.NoError(t, rootCmd.RunE(rootCmd, nil), name)
+		assert.FileExists(t, filepath.Join(ts.Dir(), "ran"), name)
+		assert.NotContains(t, out.String(), "Hello from gocli", name)
+	}
+	rootCmd.SetOut(nil)
+}
+
+func TestRootAppliesRunVariables(t *testing.T) {
+	ts := writeTaskFile(t, `
+default: build
+tasks:
+  build:
+    cmd: echo run >> runs
+    inputs: [zr.yaml]
+    outputs: [runs]
+`)
+	chdir(t, ts.Dir())
+	rootCmd.SetOut(&bytes.Buffer{})
+	rootCmd.SetContext(context.Background())
+	t.Cleanup(func() {
+		rootCmd.SetOut(nil)
+		forceRun = false
+	})
+	require.NoError(t, rootCmd.RunE(rootCmd, nil))
+
+	t.Setenv("ZR_NO_CACHE", "1")
+	require.NoError(t, rootCmd.RunE(rootCmd, nil))
+	data, err := os.ReadFile(filepath.Join(ts.Dir(), "runs"))
+	require.NoError(t, err)
+	assert.Equal(t, "run\nrun\n", string(data), "ZR_NO_CACHE reruns the up to date default task")
+}
+
+func TestRootWithoutDefaultPrintsHello(t *testing.T) {
+	for _, dir := range []string{t.TempDir(), writeTaskFile(t, "tasks:\n  build: {}\n").Dir()} {
+		chdir(t, dir)
+		var out bytes.Buffer
+		rootCmd.SetOut(&out)
+		require.NoError(t, rootCmd.RunE(rootCmd, nil))
+		assert.Contains(t, out.String(), "Hello from gocli")
+	}
+	rootCmd.SetOut(nil)
+}
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, 130, ExitCode(errInterrupted))
+	assert.Equal(t, 130, ExitCode(fmt.Errorf("run: %w", errInterrupted)))
+	assert.Equal(t, 1, ExitCode(errors.New("boom")))
+}
+
+func TestLoadDefaultTaskFileFlag(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"ci/tasks.yaml": "tasks:\n  build: {cmd: go build}\n",
+	})
+	chdir(t, root)
+	t.Cleanup(func() { taskFilePath = "" })
+
+	taskFilePath = "ci/tasks.yaml"
+	ts, err := loadDefaultTaskFile()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build"}, ts.Names())
+
+	taskFilePath = "ci/missing.yaml"
+	_, err = loadDefaultTaskFile()
+	assert.EqualError(t, err, "task file "+filepath.Join(root, "ci/missing.yaml")+" does not exist")
+}
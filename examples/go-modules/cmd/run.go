@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/example/gocli/zr"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dryRun          bool
+	continueOnError bool
+	onlyNamed       bool
+	jobs            int
+	prefixOutput    bool
+	showTimings     bool
+	reportPath      string
+	forceRun        bool
+	fromTask        string
+	logDir          string
+	remoteCache     string
+	selectPatterns  []string
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run [<task>...] [--select <pattern>]... [-- <args>...]",
+	Short: "Run tasks and their dependencies",
+	Long: `Run tasks and their dependencies.
+
+Arguments after -- are appended to the command of the named task, which must
+be a single task with a single-line command, e.g. zr run test -- -run TestFoo.
+
+--select adds every task whose name matches a shell-style pattern, e.g.
+zr run --select 'test:*' runs test:unit, test:integration and so on. A
+pattern that matches no task is an error.
+
+A task starts once its deps have succeeded and the tasks in its run_after
+list have finished, if they are part of the run; run_after orders tasks
+without adding them to the run or needing them to succeed. Tasks with no
+such relation between them can run at the same time, up to --jobs at once.
+
+When a task's command fails, zr exits with the command's exit code. If
+several tasks fail, as they can with --continue-on-error or parallel jobs,
+the code is that of the task that failed first.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 && len(selectPatterns) == 0 {
+			return errors.New("requires a task or --select")
+		}
+		return nil
+	},
+	SilenceUsage:      true,
+	ValidArgsFunction: completeTaskNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ts, err := loadDefaultTaskFile()
+		if err != nil {
+			return err
+		}
+		if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+			if dash != 1 {
+				return fmt.Errorf("arguments after -- need exactly one task, got %d", dash)
+			}
+			if err := ts.AppendArgs(args[0], args[dash:]); err != nil {
+				return err
+			}
+			args = args[:dash]
+		}
+		for _, pattern := range selectPatterns {
+			selected, err := ts.Select(pattern)
+			if err != nil {
+				return err
+			}
+			args = append(args, selected...)
+		}
+		planFor := ts.Plan
+		if onlyNamed {
+			planFor = ts.PlanOnly
+		}
+		plan, err := planFor(args)
+		if err == nil && fromTask != "" {
+			plan, err = ts.StartFrom(plan, fromTask)
+		}
+		if err != nil {
+			return err
+		}
+		if dryRun {
+			printLevels(cmd.OutOrStdout(), plan, true)
+			return nil
+		}
+		return executePlan(cmd, ts, plan)
+	},
+}
+
+// executePlan runs plan with options taken from the run flags, then prints
+// the summary, timings and log files and writes the report they ask for.
+func executePlan(cmd *cobra.Command, ts *zr.TaskSet, plan zr.Plan) error {
+	// The run flags are only parsed for run, but their variables apply to
+	// the other commands running tasks too.
+	if cmd.Name() != "run" {
+		run, _, err := cmd.Root().Find([]string{"run"})
+		if err != nil {
+			return err
+		}
+		if err := applyEnvDefaults(run.Flags()); err != nil {
+			return err
+		}
+	}
+	opts := zr.RunOptions{
+		Stdout:    cmd.OutOrStdout(),
+		Stderr:    cmd.ErrOrStderr(),
+		Jobs:      jobs,
+		KeepGoing: continueOnError,
+		Prefix:    prefixOutput || (jobs != 1 && plan.Parallel()),
+		Verbose:   verbose,
+		Force:     forceRun,
+		LogDir:    logDir,
+		Confirm:   confirmTask,
+	}
+	if remoteCache != "" {
+		opts.Remote = &zr.HTTPCache{URL: remoteCache, Token: os.Getenv("ZR_REMOTE_CACHE_TOKEN")}
+	}
+	// --jobs 0 means no cap, where zr takes zero to mean runtime.NumCPU().
+	if jobs == 0 {
+		opts.Jobs = -1
+	}
+	res, err := ts.Execute(cmd.Context(), plan, opts)
+	if res == nil {
+		return err
+	}
+	if continueOnError {
+		printSummary(opts.Stdout, res)
+	}
+	if showTimings || plan.Size() > 1 {
+		printTimings(opts.Stdout, res)
+	}
+	if logDir != "" {
+		printLogs(opts.Stdout, res)
+	}
+	if reportPath != "" {
+		if reportErr := newRunReport(res).write(reportPath); reportErr != nil {
+			return errors.Join(err, reportErr)
+		}
+	}
+	return err
+}
+
+func init() {
+	runCmd.Flags().BoolVar(&dryRun, "dry-run", false,
+		"Print the execution plan without running anything")
+	runCmd.Flags().BoolVarP(&continueOnError, "continue-on-error", "k", false,
+		"Keep running tasks that don't depend on a failed task")
+	runCmd.Flags().StringArrayVar(&selectPatterns, "select", nil,
+		"Also run every task whose name matches this shell-style pattern (repeatable)")
+	runCmd.RegisterFlagCompletionFunc("select", cobra.NoFileCompletions)
+	runCmd.Flags().BoolVar(&onlyNamed, "only", false,
+		"Run only the named tasks, ignoring their dependencies")
+	runCmd.Flags().StringVar(&fromTask, "from", "",
+		"Resume at this task, running only it and the planned tasks that depend on it")
+	runCmd.RegisterFlagCompletionFunc("from", completeTaskNames)
+	runCmd.Flags().IntVarP(&jobs, "jobs", "j", runtime.NumCPU(),
+		"Maximum number of tasks to run at once")
+	runCmd.Flags().BoolVar(&prefixOutput, "prefix", false,
+		"Prefix task output with the task name (default when tasks run in parallel)")
+	runCmd.Flags().BoolVar(&showTimings, "timings", false,
+		"Print how long each task took (default when running more than one task)")
+	runCmd.Flags().StringVar(&reportPath, "report", "",
+		"Write a JSON report of the run to this file, even if it fails")
+	runCmd.Flags().StringVar(&logDir, "log-dir", "",
+		"Also write the output of each task to <task>.log in this directory")
+	runCmd.Flags().StringVar(&remoteCache, "remote-cache", "",
+		"Share the outputs of cacheable tasks through the HTTP cache server at this URL")
+	runCmd.Flags().BoolVar(&forceRun, "force", false,
+		"Run every task even if it is up to date or cached, still recording cache entries")
+	runCmd.Flags().BoolVar(&forceRun, "no-cache", false, "Same as --force")
+}
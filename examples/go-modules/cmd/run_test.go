@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chdir switches into dir for the duration of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestRunDryRunDoesNotExecute(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  build: {cmd: touch built}
+  test: {cmd: touch tested, deps: [build]}
+`)
+	dir := filepath.Dir(ts.Path)
+	chdir(t, dir)
+
+	var out bytes.Buffer
+	runCmd.SetOut(&out)
+	dryRun = true
+	t.Cleanup(func() { dryRun = false })
+	require.NoError(t, runCmd.RunE(runCmd, []string{"test"}))
+
+	assert.Contains(t, out.String(), "Level 0:")
+	assert.Contains(t, out.String(), "$ touch tested")
+	assert.NoFileExists(t, filepath.Join(dir, "built"))
+	assert.NoFileExists(t, filepath.Join(dir, "tested"))
+}
+
+func TestRunOnlySkipsDependencies(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  build: {cmd: touch built}
+  test: {cmd: touch tested, deps: [build]}
+`)
+	chdir(t, ts.Dir())
+	runCmd.SetOut(&bytes.Buffer{})
+	runCmd.SetContext(context.Background())
+	onlyNamed = true
+	t.Cleanup(func() { onlyNamed = false })
+
+	require.NoError(t, runCmd.RunE(runCmd, []string{"test"}))
+	assert.FileExists(t, filepath.Join(ts.Dir(), "tested"))
+	assert.NoFileExists(t, filepath.Join(ts.Dir(), "built"))
+
+	assert.EqualError(t, runCmd.RunE(runCmd, []string{"nope"}), `unknown task "nope"`)
+}
+
+func TestRunFromResumesPipeline(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  build: {cmd: echo build >> log}
+  test: {cmd: echo test >> log, deps: [build]}
+  package: {cmd: echo package >> log, deps: [test]}
+`)
+	chdir(t, ts.Dir())
+	runCmd.SetOut(&bytes.Buffer{})
+	runCmd.SetContext(context.Background())
+	fromTask = "test"
+	t.Cleanup(func() { fromTask = "" })
+
+	require.NoError(t, runCmd.RunE(runCmd, []string{"package"}))
+	data, err := os.ReadFile(filepath.Join(ts.Dir(), "log"))
+	require.NoError(t, err)
+	assert.Equal(t, "test\npackage\n", string(data))
+
+	fromTask = "package"
+	assert.EqualError(t, runCmd.RunE(runCmd, []string{"test"}), `task "package" is not part of the plan`)
+}
+
+func TestRunSelect(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  build: {cmd: touch built}
+  test:unit: {cmd: touch unit, deps: [build]}
+  test:e2e: {cmd: touch e2e, deps: [build]}
+  lint: {cmd: touch linted}
+`)
+	chdir(t, ts.Dir())
+	runCmd.SetOut(&bytes.Buffer{})
+	runCmd.SetContext(context.Background())
+	t.Cleanup(func() { selectPatterns = nil })
+
+	selectPatterns = []string{"test:*"}
+	require.NoError(t, runCmd.RunE(runCmd, nil))
+	for _, f := range []string{"built", "unit", "e2e"} {
+		assert.FileExists(t, filepath.Join(ts.Dir(), f))
+	}
+	assert.NoFileExists(t, filepath.Join(ts.Dir(), "linted"))
+
+	selectPatterns = []string{"deploy:*"}
+	assert.EqualError(t, runCmd.RunE(runCmd, []string{"lint"}), `no task matches "deploy:*"`)
+	assert.NoFileExists(t, filepath.Join(ts.Dir(), "linted"), "nothing runs when a pattern matches nothing")
+
+	selectPatterns = nil
+	assert.EqualError(t, runCmd.Args(runCmd, nil), "requires a task or --select")
+}
+
+func TestRunPassesArgsAfterDash(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  greet: {cmd: printf '%s|' > args}
+  multi: {cmd: "echo a\necho b\n"}
+`)
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	t.Cleanup(func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetArgs(nil)
+		taskFilePath = ""
+		// Parsing never clears where a -- was seen; only Init does, and
+		// tests calling RunE directly would see it.
+		runCmd.Flags().Init(runCmd.Name(), pflag.ContinueOnError)
+	})
+
+	rootCmd.SetArgs([]string{"run", "-f", ts.Path, "greet", "--", "-run", "Test Foo"})
+	require.NoError(t, rootCmd.Execute())
+	data, err := os.ReadFile(filepath.Join(ts.Dir(), "args"))
+	require.NoError(t, err)
+	assert.Equal(t, "-run|Test Foo|", string(data))
+
+	rootCmd.SetArgs([]string{"run", "-f", ts.Path, "multi", "--", "-v"})
+	assert.EqualError(t, rootCmd.Execute(), `task "multi" is not a single command, so it can't take arguments`)
+
+	rootCmd.SetArgs([]string{"run", "-f", ts.Path, "greet", "multi", "--", "-v"})
+	assert.EqualError(t, rootCmd.Execute(), "arguments after -- need exactly one task, got 2")
+}
+
+func TestRunExitCodeOfFailedTask(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  lint: {cmd: exit 2}
+  slow: {cmd: sleep 0.2; exit 3}
+  all: {deps: [lint, slow]}
+`)
+	runCmd.SetOut(&bytes.Buffer{})
+	runCmd.SetErr(&bytes.Buffer{})
+	runCmd.SetContext(context.Background())
+	t.Cleanup(func() {
+		runCmd.SetOut(nil)
+		runCmd.SetErr(nil)
+		continueOnError, jobs = false, 0
+	})
+
+	plan, err := ts.Plan([]string{"slow"})
+	require.NoError(t, err)
+	err = executePlan(runCmd, ts, plan)
+	require.Error(t, err)
+	assert.Equal(t, 3, ExitCode(err))
+
+	continueOnError, jobs = true, 2
+	plan, err = ts.Plan([]string{"all"})
+	require.NoError(t, err)
+	err = executePlan(runCmd, ts, plan)
+	assert.EqualError(t, err, "2 of 3 tasks failed")
+	assert.Equal(t, 2, ExitCode(err), "the task that failed first decides")
+}
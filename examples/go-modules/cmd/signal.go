@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/example/gocli/zr"
+)
+
+// exitInterrupted is the exit code after SIGINT or SIGTERM, following the
+// shell convention of 128 plus the signal number of SIGINT.
+const exitInterrupted = 130
+
+// errInterrupted is returned when a signal stopped zr.
+var errInterrupted = errors.New("interrupted")
+
+// ExitCode returns the process exit code for an error returned by Execute.
+// When a task's command failed, that is the command's own exit code; when
+// several tasks failed, it is the code of the one that failed first. Any
+// other failure exits with 1.
+func ExitCode(err error) int {
+	if errors.Is(err, errInterrupted) {
+		return exitInterrupted
+	}
+	if code := exitCodeOf(err); code != nil && *code > 0 {
+		return *code
+	}
+	return 1
+}
+
+// handleInterrupts returns a context that is cancelled on the first SIGINT
+// or SIGTERM, which stops every running task's process group. A second
+// signal kills the groups still running and exits at once. interrupted
+// reports whether a signal was received, and stop restores the default
+// handling of the signals once the run is over.
+func handleInterrupts(parent context.Context) (ctx context.Context, interrupted func() bool, stop func()) {
+	ctx, cancel := context.WithCancel(parent)
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	var got atomic.Bool
+	go func() {
+		select {
+		case <-sigs:
+		case <-done:
+			return
+		}
+		got.Store(true)
+		fmt.Fprintln(newPrinter(os.Stderr), colorize(colorYellow, "Stopping tasks, interrupt again to force"))
+		cancel()
+		select {
+		case <-sigs:
+		case <-done:
+			return
+		}
+		zr.KillRunning()
+		os.Exit(exitInterrupted)
+	}()
+	stop = func() {
+		signal.Stop(sigs)
+		close(done)
+		cancel()
+	}
+	return ctx, got.Load, stop
+}
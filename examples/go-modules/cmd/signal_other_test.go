@@ -0,0 +1,61 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/example/gocli/zr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterruptStopsRunningTasks(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  a: {cmd: "sleep 30 & echo $! > a.pid; wait"}
+  b: {cmd: "sleep 30 & echo $! > b.pid; wait"}
+`)
+	ctx, interrupted, stop := handleInterrupts(context.Background())
+	defer stop()
+	done := make(chan error, 1)
+	go func() {
+		_, err := ts.Run(ctx, []string{"a", "b"}, zr.RunOptions{Stdout: &bytes.Buffer{}, Jobs: 2})
+		done <- err
+	}()
+
+	var pids []int
+	for _, name := range []string{"a.pid", "b.pid"} {
+		path := filepath.Join(ts.Dir(), name)
+		require.Eventually(t, func() bool {
+			data, err := os.ReadFile(path)
+			return err == nil && strings.HasSuffix(string(data), "\n")
+		}, 5*time.Second, 20*time.Millisecond)
+		data, _ := os.ReadFile(path)
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		require.NoError(t, err)
+		pids = append(pids, pid)
+	}
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGINT))
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not stop after SIGINT")
+	}
+	assert.True(t, interrupted())
+	for _, pid := range pids {
+		assert.Eventually(t, func() bool {
+			return syscall.Kill(pid, 0) != nil
+		}, 10*time.Second, 20*time.Millisecond, "background child should be gone")
+	}
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/example/gocli/zr"
+)
+
+// taskFilePath is set by the global --file flag and overrides discovery.
+var taskFilePath string
+
+// loadDefaultTaskFile loads the task file given with --file or, without it,
+// discovers the one for the current directory.
+func loadDefaultTaskFile() (*zr.TaskSet, error) {
+	if taskFilePath != "" {
+		abs, err := filepath.Abs(taskFilePath)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(abs); err != nil {
+			return nil, fmt.Errorf("task file %s does not exist", abs)
+		}
+		return zr.LoadTasks(abs)
+	}
+	path, err := zr.FindTaskFile(".")
+	if err != nil {
+		return nil, err
+	}
+	return zr.LoadTasks(path)
+}
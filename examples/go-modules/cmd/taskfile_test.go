@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/example/gocli/zr"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTaskFile writes content as the task file of a fresh directory and
+// loads it.
+func writeTaskFile(t *testing.T, content string) *zr.TaskSet {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), zr.DefaultFileName)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	ts, err := zr.LoadTasks(path)
+	require.NoError(t, err)
+	return ts
+}
+
+// writeFiles creates files, keyed by slash-separated path, in a fresh
+// directory and returns it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+	return root
+}
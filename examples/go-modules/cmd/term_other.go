@@ -0,0 +1,29 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// makeRaw puts the terminal behind f into raw mode, without echo, so keys
+// are read one at a time. stty is used rather than ioctls because the
+// request numbers differ across Unix systems. restore undoes the change.
+func makeRaw(f *os.File) (restore func(), err error) {
+	stty := func(args ...string) (string, error) {
+		c := exec.Command("stty", args...)
+		c.Stdin = f
+		out, err := c.Output()
+		return strings.TrimSpace(string(out)), err
+	}
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() { stty(saved) }, nil
+}
@@ -0,0 +1,30 @@
+//go:build windows
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	enableProcessedInput       = 0x0001
+	enableLineInput            = 0x0002
+	enableEchoInput            = 0x0004
+	enableVirtualTerminalInput = 0x0200
+)
+
+// makeRaw switches the console behind f to unbuffered input without echo,
+// reporting arrow keys as VT escape sequences. restore undoes the change.
+func makeRaw(f *os.File) (restore func(), err error) {
+	h := syscall.Handle(f.Fd())
+	var mode uint32
+	if err := syscall.GetConsoleMode(h, &mode); err != nil {
+		return nil, err
+	}
+	raw := mode&^(enableProcessedInput|enableLineInput|enableEchoInput) | enableVirtualTerminalInput
+	if r, _, err := procSetConsoleMode.Call(uintptr(h), uintptr(raw)); r == 0 {
+		return nil, err
+	}
+	return func() { procSetConsoleMode.Call(uintptr(h), uintptr(mode)) }, nil
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/example/gocli/zr"
 	"github.com/spf13/cobra"
 )
 
@@ -10,6 +11,6 @@ var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print the version number",
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("gocli v1.0.0")
+		fmt.Println("gocli " + zr.Version)
 	},
 }
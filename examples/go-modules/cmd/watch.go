@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// pollInterval is how often the watcher rescans the tree.
+const pollInterval = 100 * time.Millisecond
+
+// watcher polls a directory tree and reports files that were created,
+// modified or removed. Polling keeps it portable and dependency-free.
+type watcher struct {
+	root string
+	// files are watched on top of the tree, so files outside it can be.
+	files []string
+	// ignore reports whether a slash-separated path relative to root
+	// should not be watched.
+	ignore   func(rel string) bool
+	snapshot map[string]time.Time
+}
+
+// newWatcher takes an initial snapshot of root and files. The .git and .zr
+// directories are always ignored.
+func newWatcher(root string, files []string, ignore func(rel string) bool) (*watcher, error) {
+	w := &watcher{root: root, files: files, ignore: ignore}
+	snap, err := w.scan()
+	if err != nil {
+		return nil, err
+	}
+	w.snapshot = snap
+	return w, nil
+}
+
+func (w *watcher) scan() (map[string]time.Time, error) {
+	snap := map[string]time.Time{}
+	err := filepath.WalkDir(w.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Files may vanish between listing and stat.
+			return nil
+		}
+		rel, err := filepath.Rel(w.root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if d.Name() == ".git" || d.Name() == ".zr" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if w.ignore != nil && w.ignore(rel) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		snap[path] = info.ModTime()
+		return nil
+	})
+	for _, path := range w.files {
+		if _, ok := snap[path]; ok {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			snap[path] = info.ModTime()
+		}
+	}
+	return snap, err
+}
+
+// changes rescans the tree and returns the paths that differ from the
+// previous scan, sorted.
+func (w *watcher) changes() ([]string, error) {
+	snap, err := w.scan()
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	for path, mt := range snap {
+		if prev, ok := w.snapshot[path]; !ok || !prev.Equal(mt) {
+			changed = append(changed, path)
+		}
+	}
+	for path := range w.snapshot {
+		if _, ok := snap[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	w.snapshot = snap
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// watch polls every interval until ctx is done, sending each non-empty set
+// of changes.
+func (w *watcher) watch(ctx context.Context, interval time.Duration) <-chan []string {
+	out := make(chan []string)
+	go func() {
+		defer close(out)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			changed, err := w.changes()
+			if err != nil || len(changed) == 0 {
+				continue
+			}
+			select {
+			case out <- changed:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// debounce merges change sets arriving less than window apart and sends the
+// merged set once no change has arrived for a full window.
+func debounce(ctx context.Context, in <-chan []string, window time.Duration) <-chan []string {
+	out := make(chan []string)
+	go func() {
+		defer close(out)
+		var pending []string
+		seen := map[string]bool{}
+		timer := time.NewTimer(window)
+		timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case changed, ok := <-in:
+				if !ok {
+					return
+				}
+				for _, p := range changed {
+					if !seen[p] {
+						seen[p] = true
+						pending = append(pending, p)
+					}
+				}
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(window)
+			case <-timer.C:
+				select {
+				case out <- pending:
+				case <-ctx.Done():
+					return
+				}
+				pending = nil
+				seen = map[string]bool{}
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/example/gocli/internal/glob"
+	"github.com/example/gocli/zr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcherChanges(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"main.go":     "package main",
+		"bin/app":     "",
+		".zr/cache/x": "",
+	})
+	w, err := newWatcher(root, nil, glob.CompileSet([]string{"bin/"}).Matches)
+	require.NoError(t, err)
+
+	changed, err := w.changes()
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "new.go"), nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "bin/app"), []byte("x"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".zr/cache/y"), nil, 0o644))
+	require.NoError(t, os.Remove(filepath.Join(root, "main.go")))
+	changed, err = w.changes()
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "main.go"), filepath.Join(root, "new.go")}, changed)
+}
+
+func TestDebounceMergesBursts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	in := make(chan []string)
+	out := debounce(ctx, in, 50*time.Millisecond)
+
+	in <- []string{"a"}
+	in <- []string{"b", "a"}
+	time.Sleep(10 * time.Millisecond)
+	in <- []string{"c"}
+	select {
+	case got := <-out:
+		assert.Equal(t, []string{"a", "b", "c"}, got)
+	case <-time.After(time.Second):
+		t.Fatal("debounced batch never arrived")
+	}
+	select {
+	case got := <-out:
+		t.Fatalf("unexpected second batch %v", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for use by a running session and the
+// test reading it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRunLiveRerunsOnChange(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  build: {cmd: "echo run >> ../runs.log"}
+`)
+	src := filepath.Join(ts.Dir(), "main.go")
+	require.NoError(t, os.WriteFile(src, nil, 0o644))
+	log := filepath.Join(filepath.Dir(ts.Dir()), "runs.log")
+	runs := func() int {
+		data, _ := os.ReadFile(log)
+		return strings.Count(string(data), "run\n")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var out syncBuffer
+	done := make(chan error)
+	go func() {
+		done <- runLive(ctx, &out, &out, ts, []string{"build"}, liveOptions{debounce: 20 * time.Millisecond})
+	}()
+
+	require.Eventually(t, func() bool { return runs() == 1 }, 2*time.Second, 10*time.Millisecond)
+	require.NoError(t, os.WriteFile(src, []byte("package main"), 0o644))
+	require.Eventually(t, func() bool { return runs() == 2 }, 2*time.Second, 10*time.Millisecond)
+	assert.Contains(t, out.String(), "changed: main.go")
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestRunLiveReloadsTaskFile(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  build: {cmd: "echo one >> ../runs.log"}
+`)
+	log := filepath.Join(filepath.Dir(ts.Dir()), "runs.log")
+	runs := func() string {
+		data, _ := os.ReadFile(log)
+		return string(data)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var out syncBuffer
+	done := make(chan error)
+	go func() {
+		done <- runLive(ctx, &out, &out, ts, []string{"build"}, liveOptions{debounce: 20 * time.Millisecond})
+	}()
+
+	require.Eventually(t, func() bool { return runs() == "one\n" }, 2*time.Second, 10*time.Millisecond)
+	require.NoError(t, os.WriteFile(ts.Path, []byte("tasks:\n  build: {cmd: \"echo two >> ../runs.log\"}\n"), 0o644))
+	require.Eventually(t, func() bool { return runs() == "one\ntwo\n" }, 2*time.Second, 10*time.Millisecond)
+	assert.Contains(t, out.String(), "reloaded task file")
+
+	// A broken task file is reported and the last good one stays in use.
+	require.NoError(t, os.WriteFile(ts.Path, []byte("tasks:\n  build: [\n"), 0o644))
+	require.Eventually(t, func() bool { return runs() == "one\ntwo\ntwo\n" }, 2*time.Second, 10*time.Millisecond)
+	assert.Contains(t, out.String(), "(keeping the old one)")
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestLiveTargetTriggeredBy(t *testing.T) {
+	all := newLiveTarget(&zr.Task{Name: "build"})
+	assert.True(t, all.triggeredBy([]string{"docs/README.md"}))
+
+	api := newLiveTarget(&zr.Task{Name: "api", Watch: []string{"services/api/", "go.mod"}})
+	assert.True(t, api.triggeredBy([]string{"services/api/main.go"}))
+	assert.True(t, api.triggeredBy([]string{"go.mod"}))
+	assert.False(t, api.triggeredBy([]string{"services/web/index.ts", "docs/README.md"}))
+}
+
+func TestRunLiveRoutesChangesToWatchingTasks(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  api: {cmd: "echo api >> ../runs.log", watch: ["api/"]}
+  web: {cmd: "echo web >> ../runs.log", watch: ["web/"]}
+`)
+	require.NoError(t, os.MkdirAll(filepath.Join(ts.Dir(), "web"), 0o755))
+	log := filepath.Join(filepath.Dir(ts.Dir()), "runs.log")
+	count := func(name string) int {
+		data, _ := os.ReadFile(log)
+		return strings.Count(string(data), name+"\n")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var out syncBuffer
+	done := make(chan error)
+	go func() {
+		done <- runLive(ctx, &out, &out, ts, []string{"api", "web"}, liveOptions{debounce: 20 * time.Millisecond})
+	}()
+
+	require.Eventually(t, func() bool { return count("api") == 1 && count("web") == 1 }, 2*time.Second, 10*time.Millisecond)
+	require.NoError(t, os.WriteFile(filepath.Join(ts.Dir(), "web/index.ts"), nil, 0o644))
+	require.Eventually(t, func() bool { return count("web") == 2 }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, count("api"))
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestPrintLiveHeader(t *testing.T) {
+	at := time.Date(2024, 5, 1, 14, 3, 5, 0, time.UTC)
+	var out bytes.Buffer
+	printLiveHeader(&out, false, "main.go", []string{"test", "lint"}, at)
+	assert.Equal(t, "zr live 14:03:05\nchanged: main.go\nrunning: test, lint\n\n", sgrPattern.ReplaceAllString(out.String(), ""))
+
+	out.Reset()
+	printLiveHeader(&out, true, "", []string{"test"}, at)
+	assert.True(t, strings.HasPrefix(out.String(), "\x1b[H\x1b[2J"), "clears the screen first")
+	assert.Contains(t, out.String(), "initial run")
+}
+
+func TestPrintLiveStatus(t *testing.T) {
+	var out bytes.Buffer
+	printLiveStatus(&out, nil, 1500*time.Millisecond)
+	assert.Contains(t, out.String(), "✓ passed")
+	assert.Contains(t, out.String(), "1.5s")
+
+	out.Reset()
+	printLiveStatus(&out, errors.New("boom"), time.Second)
+	assert.Contains(t, out.String(), "✗ failed")
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/example/gocli/zr"
+	"github.com/spf13/cobra"
+)
+
+var whyCmd = &cobra.Command{
+	Use:   "why <task>",
+	Short: "Explain whether a task would run or be skipped, and why",
+	Long: `Explain whether running a task now would run it or skip it as up to date
+or cached: which outputs are missing, which inputs are newer than the
+outputs, whether the cache key matches a successful run, and which
+dependencies would run first. Nothing is run.`,
+	Args:              cobra.ExactArgs(1),
+	SilenceUsage:      true,
+	ValidArgsFunction: completeTaskNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ts, err := loadDefaultTaskFile()
+		if err != nil {
+			return err
+		}
+		x, err := ts.Explain(args[0])
+		if err != nil {
+			return err
+		}
+		printExplanation(cmd.OutOrStdout(), x)
+		return nil
+	},
+}
+
+// printExplanation writes the verdict on a task followed by the reasons
+// for it.
+func printExplanation(w io.Writer, x *zr.Explanation) {
+	verdict := colorize(colorYellow, "will run")
+	if x.Skip != "" {
+		verdict = colorize(colorGreen, "will be skipped, "+x.Skip)
+	}
+	fmt.Fprintf(w, "%s %s\n", colorize(colorCyan, x.Task.Name), verdict)
+	for _, r := range x.Reasons {
+		fmt.Fprintln(w, "  "+r)
+	}
+	for _, dep := range x.DepsToRun {
+		line := fmt.Sprintf("  dependency %s will run first", dep)
+		if x.Skip != "" {
+			line += colorize(colorDim, " and may change the inputs")
+		}
+		fmt.Fprintln(w, line)
+	}
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/example/gocli/zr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintExplanation(t *testing.T) {
+	var out bytes.Buffer
+	printExplanation(&out, &zr.Explanation{
+		Task:      &zr.Task{Name: "build"},
+		Reasons:   []string{"input main.go is newer than output app"},
+		DepsToRun: []string{"gen"},
+	})
+	assert.Equal(t, ""+
+		"build will run\n"+
+		"  input main.go is newer than output app\n"+
+		"  dependency gen will run first\n", sgrPattern.ReplaceAllString(out.String(), ""))
+
+	out.Reset()
+	printExplanation(&out, &zr.Explanation{
+		Task:      &zr.Task{Name: "build"},
+		Skip:      "up to date",
+		Reasons:   []string{"all 1 outputs are newer than all 2 inputs"},
+		DepsToRun: []string{"gen"},
+	})
+	assert.Equal(t, ""+
+		"build will be skipped, up to date\n"+
+		"  all 1 outputs are newer than all 2 inputs\n"+
+		"  dependency gen will run first and may change the inputs\n", sgrPattern.ReplaceAllString(out.String(), ""))
+}
@@ -0,0 +1,126 @@
+// Package glob matches slash-separated relative paths against
+// .gitignore-style patterns.
+package glob
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Pattern is one compiled .gitignore-style pattern.
+type Pattern struct {
+	segments []string
+	negate   bool
+}
+
+// Compile converts a .gitignore-style pattern into path segments:
+//   - a leading "!" negates the pattern
+//   - a pattern without a slash matches at any depth
+//   - a leading "/" anchors the pattern to the root
+//   - a trailing "/" or a match on a directory covers everything beneath it
+//   - "**" matches any number of directories
+func Compile(pattern string) Pattern {
+	var g Pattern
+	if strings.HasPrefix(pattern, "!") {
+		g.negate = true
+		pattern = pattern[1:]
+	}
+	pattern = strings.TrimSuffix(pattern, "/")
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+	g.segments = strings.Split(pattern, "/")
+	return g
+}
+
+// Matches reports whether rel, a slash-separated path relative to the
+// root, or any of its parent directories matches the pattern.
+func (g Pattern) Matches(rel string) bool {
+	parts := strings.Split(rel, "/")
+	for i := 1; i <= len(parts); i++ {
+		if matchSegments(g.segments, parts[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// Negated reports whether the pattern started with "!".
+func (g Pattern) Negated() bool {
+	return g.negate
+}
+
+// Set is an ordered list of patterns where later patterns override earlier
+// ones, as in a .gitignore file.
+type Set []Pattern
+
+// CompileSet compiles patterns, in order, into a Set.
+func CompileSet(patterns []string) Set {
+	set := make(Set, 0, len(patterns))
+	for _, p := range patterns {
+		set = append(set, Compile(p))
+	}
+	return set
+}
+
+// Matches reports whether the last pattern matching rel isn't negated.
+func (s Set) Matches(rel string) bool {
+	matched := false
+	for _, g := range s {
+		if g.Matches(rel) {
+			matched = !g.negate
+		}
+	}
+	return matched
+}
+
+// Expand returns the files under root matching patterns, as paths joined
+// to root. The .git directory is never descended into.
+func Expand(root string, patterns []string) ([]string, error) {
+	set := CompileSet(patterns)
+	var files []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if set.Matches(filepath.ToSlash(rel)) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files, err
+}
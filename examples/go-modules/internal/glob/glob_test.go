@@ -0,0 +1,43 @@
+package glob
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetMatches(t *testing.T) {
+	cases := []struct {
+		patterns []string
+		path     string
+		want     bool
+	}{
+		{[]string{"*.go"}, "main.go", true},
+		{[]string{"*.go"}, "cmd/root.go", true},
+		{[]string{"/*.go"}, "cmd/root.go", false},
+		{[]string{"cmd/*.go"}, "cmd/root.go", true},
+		{[]string{"**/*_test.go"}, "a/b/c_test.go", true},
+		{[]string{"bin/"}, "bin/gocli", true},
+		{[]string{"cmd"}, "cmd/root.go", true},
+		{[]string{"*.go", "!*_test.go"}, "cmd/greet_test.go", false},
+		{[]string{"*.go", "!*_test.go", "greet_test.go"}, "cmd/greet_test.go", true},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, CompileSet(c.patterns).Matches(c.path), "%v against %s", c.patterns, c.path)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	root := t.TempDir()
+	for _, f := range []string{"main.go", "cmd/root.go", "cmd/root_test.go", ".git/HEAD.go", "README.md"} {
+		p := filepath.Join(root, f)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, nil, 0o644))
+	}
+	files, err := Expand(root, []string{"*.go", "!*_test.go"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{filepath.Join(root, "main.go"), filepath.Join(root, "cmd/root.go")}, files)
+}
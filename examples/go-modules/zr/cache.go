@@ -0,0 +1,218 @@
+package zr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/example/gocli/internal/glob"
+)
+
+// Cache records which cache keys belong to successful task runs.
+// Entries are small JSON files named after their key.
+type Cache struct {
+	dir string
+}
+
+// CacheEntry is the record stored for a successful run.
+type CacheEntry struct {
+	Task    string    `json:"task"`
+	Key     string    `json:"key"`
+	Created time.Time `json:"created"`
+}
+
+func newCache(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// Cache returns the cache of the task set, kept in .zr/cache next to the
+// task file.
+func (ts *TaskSet) Cache() *Cache {
+	return newCache(ts.cacheDir())
+}
+
+// Dir returns the directory the cache entries are stored in.
+func (s *Cache) Dir() string {
+	return s.dir
+}
+
+// cacheDir returns the directory the task file's cache lives in.
+func (ts *TaskSet) cacheDir() string {
+	return filepath.Join(ts.Dir(), ".zr", "cache")
+}
+
+func (s *Cache) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
+
+// lookup reports whether an entry for key exists.
+func (s *Cache) lookup(key string) (bool, error) {
+	_, err := os.Stat(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// record stores e under its key. The entry is written to a temporary file
+// and renamed into place so concurrent runs never see a partial entry.
+func (s *Cache) record(e CacheEntry) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, ".entry-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(e.Key))
+}
+
+// Entries returns every stored entry. A missing cache directory simply
+// holds no entries.
+func (s *Cache) Entries() ([]CacheEntry, error) {
+	files, err := os.ReadDir(s.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []CacheEntry
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, f.Name()))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var e CacheEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name(), err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Size returns the total size in bytes of the cache directory.
+func (s *Cache) Size() (int64, error) {
+	var total int64
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	return total, err
+}
+
+// Remove deletes the entries of task, or all entries when task is empty,
+// and returns how many were removed. Entries deleted by someone else in the
+// meantime are not counted.
+func (s *Cache) Remove(task string) (int, error) {
+	entries, err := s.Entries()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, e := range entries {
+		if task != "" && e.Task != task {
+			continue
+		}
+		err := os.Remove(s.path(e.Key))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// cacheKey hashes everything that determines the result of running t: the
+// tool version, the task name, command, shell and cwd, the values of the
+// env variables the task file and its env files set for it, and the path
+// and contents of every input.
+func (ts *TaskSet) cacheKey(t *Task) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "version %s\ntask %s\n", Version, t.Name)
+	for _, cmd := range t.Cmd {
+		fmt.Fprintf(h, "cmd %q\n", cmd)
+	}
+	for _, cmd := range t.Before {
+		fmt.Fprintf(h, "before %q\n", cmd)
+	}
+	for _, cmd := range t.After {
+		fmt.Fprintf(h, "after %q\n", cmd)
+	}
+	fmt.Fprintf(h, "shell %q\n", ts.shellFor(t))
+	if t.Cwd != "" {
+		fmt.Fprintf(h, "cwd %q\n", t.Cwd)
+	}
+
+	for _, kv := range ts.declaredEnv(t) {
+		fmt.Fprintf(h, "env %q\n", kv)
+	}
+	for _, kv := range environList(ts.envFileVars(t)) {
+		fmt.Fprintf(h, "env_file %q\n", kv)
+	}
+
+	inputs, err := glob.Expand(ts.Dir(), t.Inputs)
+	if err != nil {
+		return "", err
+	}
+	for _, path := range inputs {
+		rel, err := filepath.Rel(ts.Dir(), path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "input %s\n", filepath.ToSlash(rel))
+		if err := hashFile(h, path); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
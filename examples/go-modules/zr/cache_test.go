@@ -0,0 +1,126 @@
+package zr
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheKeyInvalidation(t *testing.T) {
+	ts := writeTaskFile(t, `
+env: {MODE: debug}
+tasks:
+  build: {cmd: go build, inputs: ["*.go"], outputs: [bin/app], env: {CGO_ENABLED: "0"}}
+`)
+	require.NoError(t, os.WriteFile(filepath.Join(ts.Dir(), "main.go"), []byte("package main"), 0o644))
+	task := ts.Tasks["build"]
+	base, err := ts.cacheKey(task)
+	require.NoError(t, err)
+
+	same, err := ts.cacheKey(task)
+	require.NoError(t, err)
+	assert.Equal(t, base, same)
+
+	changes := map[string]func(){
+		"command":    func() { task.Cmd = CommandList{"go build -race"} },
+		"global env": func() { ts.Env["MODE"] = "release" },
+		"task env":   func() { task.Env["CGO_ENABLED"] = "1" },
+		"shell":      func() { ts.Shell = "bash" },
+		"input": func() {
+			require.NoError(t, os.WriteFile(filepath.Join(ts.Dir(), "main.go"), []byte("package app"), 0o644))
+		},
+	}
+	seen := map[string]string{base: "base"}
+	for name, change := range changes {
+		change()
+		key, err := ts.cacheKey(task)
+		require.NoError(t, err)
+		_, dup := seen[key]
+		assert.False(t, dup, "changing the %s must change the key", name)
+		seen[key] = name
+	}
+
+	task.Shell = "zsh"
+	key, err := ts.cacheKey(task)
+	require.NoError(t, err)
+	assert.NotContains(t, seen, key, "the task's own shell wins over the task file's")
+}
+
+func TestExecutorCacheHit(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  build: {cmd: "echo ran >> log; touch out", inputs: [src], outputs: [out]}
+`)
+	src := filepath.Join(ts.Dir(), "src")
+	require.NoError(t, os.WriteFile(src, []byte("v1"), 0o644))
+
+	e := newExecutor(ts, RunOptions{})
+	var out bytes.Buffer
+	e.stdout = &out
+	require.NoError(t, e.runTask(context.Background(), ts.Tasks["build"]))
+
+	// A newer mtime with unchanged content defeats the freshness check but
+	// not the content hash.
+	future := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(src, future, future))
+	out.Reset()
+	require.NoError(t, e.runTask(context.Background(), ts.Tasks["build"]))
+	assert.Equal(t, "build: cached (skipped)\n", out.String())
+
+	data, err := os.ReadFile(filepath.Join(ts.Dir(), "log"))
+	require.NoError(t, err)
+	assert.Equal(t, "ran\n", string(data))
+}
+
+func TestExecutorForceIgnoresCache(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  build: {cmd: "echo ran >> log; touch out", inputs: [src], outputs: [out]}
+`)
+	require.NoError(t, os.WriteFile(filepath.Join(ts.Dir(), "src"), []byte("v1"), 0o644))
+	e := newExecutor(ts, RunOptions{})
+	e.stdout = &bytes.Buffer{}
+	e.force = true
+	build := ts.Tasks["build"]
+	require.NoError(t, e.runTask(context.Background(), build))
+	require.NoError(t, e.runTask(context.Background(), build))
+	data, err := os.ReadFile(filepath.Join(ts.Dir(), "log"))
+	require.NoError(t, err)
+	assert.Equal(t, "ran\nran\n", string(data), "forced runs skip neither freshness nor cache")
+
+	key, err := ts.cacheKey(build)
+	require.NoError(t, err)
+	hit, err := e.cache.lookup(key)
+	require.NoError(t, err)
+	assert.True(t, hit, "forced runs still record cache entries")
+}
+
+func TestCacheStoreEnumerateAndRemove(t *testing.T) {
+	store := newCache(filepath.Join(t.TempDir(), "cache"))
+	removed, err := store.Remove("")
+	require.NoError(t, err)
+	assert.Zero(t, removed, "a missing cache directory is empty")
+
+	for _, e := range []CacheEntry{{Task: "build", Key: "k1"}, {Task: "build", Key: "k2"}, {Task: "test", Key: "k3"}} {
+		require.NoError(t, store.record(e))
+	}
+	entries, err := store.Entries()
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+	size, err := store.Size()
+	require.NoError(t, err)
+	assert.Positive(t, size)
+
+	removed, err = store.Remove("build")
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	removed, err = store.Remove("")
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+}
@@ -0,0 +1,214 @@
+package zr
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Plan is an execution plan: tasks grouped into levels, where every task in
+// level n depends only on tasks in levels below n, so the tasks of a single
+// level may run in parallel. Tasks within a level are sorted by name.
+type Plan [][]*Task
+
+// Plan resolves names and their transitive dependencies into a Plan. The
+// deps of a task decide which tasks are planned; its run_after entries
+// also place it above the planned tasks they name, so both kinds of edge
+// can close a cycle.
+func (ts *TaskSet) Plan(names []string) (Plan, error) {
+	planned, err := ts.levels(names, func(t *Task) []string { return t.Deps })
+	if err != nil {
+		return nil, err
+	}
+	level, err := ts.levels(names, func(t *Task) []string {
+		preds := t.Deps
+		for _, prev := range t.RunAfter {
+			if _, ok := planned[prev]; ok {
+				preds = append(slices.Clip(preds), prev)
+			}
+		}
+		return preds
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var levels Plan
+	for name, lvl := range level {
+		for len(levels) <= lvl {
+			levels = append(levels, nil)
+		}
+		levels[lvl] = append(levels[lvl], ts.Tasks[name])
+	}
+	for i, tasks := range levels {
+		sort.Slice(tasks, func(i, j int) bool { return tasks[i].Name < tasks[j].Name })
+		logger.Debug("planned level", "level", i, "tasks", taskNames(tasks))
+	}
+	return levels, nil
+}
+
+// levels walks the graph of tasks reachable from names over preds and
+// returns the level of each: one more than the highest level of its preds,
+// and zero for tasks without any.
+func (ts *TaskSet) levels(names []string, preds func(t *Task) []string) (map[string]int, error) {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	level := map[string]int{}
+	// stack is the current DFS path, used to report the tasks of a cycle.
+	var stack []string
+
+	var visit func(name, from string) error
+	visit = func(name, from string) error {
+		switch state[name] {
+		case visiting:
+			start := slices.Index(stack, name)
+			cycle := append(slices.Clone(stack[start:]), name)
+			return fmt.Errorf("cycle detected: %s", strings.Join(cycle, " -> "))
+		case visited:
+			return nil
+		}
+		t, ok := ts.Tasks[name]
+		if !ok {
+			if from != "" {
+				return fmt.Errorf("task %q depends on unknown task %q", from, name)
+			}
+			return fmt.Errorf("unknown task %q", name)
+		}
+		state[name] = visiting
+		stack = append(stack, name)
+		lvl := 0
+		for _, pred := range preds(t) {
+			if err := visit(pred, name); err != nil {
+				return err
+			}
+			if level[pred]+1 > lvl {
+				lvl = level[pred] + 1
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = visited
+		level[name] = lvl
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(ts.Resolve(name), ""); err != nil {
+			return nil, err
+		}
+	}
+	return level, nil
+}
+
+// PlanOnly returns names as a single level, ignoring their dependencies.
+func (ts *TaskSet) PlanOnly(names []string) (Plan, error) {
+	var tasks []*Task
+	seen := map[string]bool{}
+	for _, name := range names {
+		t, err := ts.Lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[t.Name] {
+			seen[t.Name] = true
+			tasks = append(tasks, t)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Name < tasks[j].Name })
+	return Plan{tasks}, nil
+}
+
+// StartFrom returns the part of plan that resumes a run at the task from:
+// that task and every planned task that depends on it, directly or through
+// other tasks. Everything else is left out, and the executor treats
+// dependencies missing from a plan as already satisfied.
+func (ts *TaskSet) StartFrom(plan Plan, from string) (Plan, error) {
+	start, err := ts.Lookup(from)
+	if err != nil {
+		return nil, err
+	}
+	downstream := map[string]bool{}
+	var isDownstream func(t *Task) bool
+	isDownstream = func(t *Task) bool {
+		if d, ok := downstream[t.Name]; ok {
+			return d
+		}
+		downstream[t.Name] = t == start
+		for _, dep := range t.Deps {
+			if d, ok := ts.Tasks[dep]; ok && isDownstream(d) {
+				downstream[t.Name] = true
+				break
+			}
+		}
+		return downstream[t.Name]
+	}
+
+	var resumed Plan
+	found := false
+	for _, tasks := range plan {
+		var level []*Task
+		for _, t := range tasks {
+			if isDownstream(t) {
+				level = append(level, t)
+			}
+			found = found || t == start
+		}
+		if len(level) > 0 {
+			resumed = append(resumed, level)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("task %q is not part of the plan", start.Name)
+	}
+	return resumed, nil
+}
+
+// Closure returns every task name depends on, directly or through other
+// tasks, once each and in execution order: by level, then by name.
+func (ts *TaskSet) Closure(name string) ([]*Task, error) {
+	plan, err := ts.Plan([]string{name})
+	if err != nil {
+		return nil, err
+	}
+	target := ts.Tasks[ts.Resolve(name)]
+	var deps []*Task
+	for _, tasks := range plan {
+		for _, t := range tasks {
+			if t != target {
+				deps = append(deps, t)
+			}
+		}
+	}
+	return deps, nil
+}
+
+func taskNames(tasks []*Task) []string {
+	names := make([]string, len(tasks))
+	for i, t := range tasks {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// Parallel reports whether any level of the plan holds more than one task,
+// i.e. whether tasks may run at the same time.
+func (p Plan) Parallel() bool {
+	for _, tasks := range p {
+		if len(tasks) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// Size returns the number of tasks in the plan.
+func (p Plan) Size() int {
+	n := 0
+	for _, tasks := range p {
+		n += len(tasks)
+	}
+	return n
+}
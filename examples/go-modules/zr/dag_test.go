@@ -0,0 +1,160 @@
+package zr
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTaskFile writes content as the default task file in a fresh
+// directory and loads it.
+func writeTaskFile(t *testing.T, content string) *TaskSet {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), DefaultFileName)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	ts, err := LoadTasks(path)
+	require.NoError(t, err)
+	return ts
+}
+
+func levelNames(levels [][]*Task) [][]string {
+	var out [][]string
+	for _, tasks := range levels {
+		var names []string
+		for _, t := range tasks {
+			names = append(names, t.Name)
+		}
+		out = append(out, names)
+	}
+	return out
+}
+
+func TestPlanLevels(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  fmt: {cmd: go fmt ./...}
+  vet: {cmd: go vet ./...}
+  build: {cmd: go build, deps: [fmt]}
+  test: {cmd: go test ./..., deps: [build, vet]}
+  unused: {cmd: "true"}
+`)
+	levels, err := ts.Plan([]string{"test"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"fmt", "vet"}, {"build"}, {"test"}}, levelNames(levels))
+}
+
+func TestPlanUnknownTask(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  build: {cmd: go build}
+`)
+	// Loading rejects unknown dependencies, but tasks can still be edited
+	// afterwards.
+	ts.Tasks["build"].Deps = []string{"compil"}
+	_, err := ts.Plan([]string{"build"})
+	assert.EqualError(t, err, `task "build" depends on unknown task "compil"`)
+
+	_, err = ts.Plan([]string{"nope"})
+	assert.EqualError(t, err, `unknown task "nope"`)
+}
+
+func TestPlanCycle(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  a: {deps: [b]}
+  b: {deps: [c]}
+  c: {deps: [a]}
+  d: {deps: [a]}
+`)
+	_, err := ts.Plan([]string{"a"})
+	assert.EqualError(t, err, "cycle detected: a -> b -> c -> a")
+
+	_, err = ts.Plan([]string{"d"})
+	assert.EqualError(t, err, "cycle detected: a -> b -> c -> a", "the path leading into the cycle is not part of it")
+}
+
+func TestPlanSelfLoop(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  a: {deps: [a]}
+`)
+	_, err := ts.Plan([]string{"a"})
+	assert.EqualError(t, err, "cycle detected: a -> a")
+}
+
+func TestPlanRunAfter(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  db-up: {cmd: docker compose up -d db}
+  migrate: {cmd: ./migrate, run_after: [db-up]}
+  seed: {cmd: ./seed, deps: [migrate]}
+`)
+	// Ordering alone doesn't pull db-up in...
+	plan, err := ts.Plan([]string{"seed"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"migrate"}, {"seed"}}, levelNames(plan))
+
+	// ...but places migrate above it when both run.
+	plan, err = ts.Plan([]string{"seed", "db-up"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"db-up"}, {"migrate"}, {"seed"}}, levelNames(plan))
+
+	ts.Tasks["db-up"].RunAfter = []string{"seed"}
+	_, err = ts.Plan([]string{"seed", "db-up"})
+	assert.EqualError(t, err, "cycle detected: seed -> migrate -> db-up -> seed")
+}
+
+func TestStartFrom(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  build: {cmd: go build}
+  test: {cmd: go test, deps: [build], aliases: [t]}
+  lint: {cmd: golangci-lint run}
+  deploy: {cmd: ./deploy, deps: [test, lint]}
+  docs: {cmd: make docs}
+`)
+	plan, err := ts.Plan([]string{"deploy", "docs"})
+	require.NoError(t, err)
+	resumed, err := ts.StartFrom(plan, "t")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"test"}, {"deploy"}}, levelNames(resumed))
+
+	// With --only, tasks still count as downstream through tasks left out
+	// of the plan.
+	only, err := ts.PlanOnly([]string{"deploy", "build", "lint"})
+	require.NoError(t, err)
+	resumed, err = ts.StartFrom(only, "build")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"build", "deploy"}}, levelNames(resumed))
+
+	plan, err = ts.Plan([]string{"deploy"})
+	require.NoError(t, err)
+	_, err = ts.StartFrom(plan, "docs")
+	assert.EqualError(t, err, `task "docs" is not part of the plan`)
+	_, err = ts.StartFrom(plan, "nope")
+	assert.EqualError(t, err, `unknown task "nope"`)
+}
+
+func TestClosure(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  gen: {cmd: go generate}
+  fmt: {cmd: go fmt ./...}
+  build: {cmd: go build, deps: [gen, fmt]}
+  test: {cmd: go test ./..., deps: [build, gen], aliases: [t]}
+  lint: {cmd: golangci-lint run}
+`)
+	deps, err := ts.Closure("t")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"fmt", "gen", "build"}}, levelNames([][]*Task{deps}))
+
+	deps, err = ts.Closure("lint")
+	require.NoError(t, err)
+	assert.Empty(t, deps)
+
+	_, err = ts.Closure("nope")
+	assert.EqualError(t, err, `unknown task "nope"`)
+}
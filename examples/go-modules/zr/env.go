@@ -0,0 +1,206 @@
+package zr
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// taskEnv builds the subprocess environment for t. The inherited process
+// environment is the base layer, followed by the task file's global
+// env_file and env, then the task's own env_file and env, each overriding
+// the layers before it. env values may reference variables of the layers
+// below with $VAR or ${VAR}, e.g. PATH: $PATH:/opt/bin; env file values are
+// taken literally.
+func (ts *TaskSet) taskEnv(t *Task) []string {
+	env := environMap(os.Environ())
+	maps.Copy(env, ts.fileEnv)
+	applyEnv(env, ts.Env)
+	maps.Copy(env, t.fileEnv)
+	applyEnv(env, t.Env)
+	return environList(env)
+}
+
+// envFileVars returns the variables t gets from env files.
+func (ts *TaskSet) envFileVars(t *Task) map[string]string {
+	vars := maps.Clone(ts.fileEnv)
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	maps.Copy(vars, t.fileEnv)
+	return vars
+}
+
+// loadEnvFiles reads the global env files and those of every task. Paths
+// are relative to the file that names them; one ending in ? is optional
+// and ignored if it doesn't exist.
+func (ts *TaskSet) loadEnvFiles() error {
+	var err error
+	if ts.fileEnv, err = readEnvFiles(ts.Dir(), ts.EnvFile); err != nil {
+		return err
+	}
+	for _, name := range ts.Names() {
+		t := ts.Tasks[name]
+		if t.fileEnv, err = readEnvFiles(filepath.Dir(t.source), t.EnvFile); err != nil {
+			return fmt.Errorf("task %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// readEnvFiles parses files in order, later files overriding earlier ones.
+func readEnvFiles(dir string, files []string) (map[string]string, error) {
+	vars := map[string]string{}
+	for _, f := range files {
+		path, optional := strings.CutSuffix(f, "?")
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		data, err := os.ReadFile(path)
+		if optional && errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("env_file: %w", err)
+		}
+		if err := parseEnvFile(path, string(data), vars); err != nil {
+			return nil, err
+		}
+	}
+	return vars, nil
+}
+
+// parseEnvFile adds the KEY=VALUE lines of a .env file to vars. Blank
+// lines and lines starting with # are skipped, as is an "export " prefix.
+// Double-quoted values may span lines and understand \n, \", \\ and \$;
+// single-quoted values are taken as is; unquoted values end at " #".
+func parseEnvFile(path, data string, vars map[string]string) error {
+	lines := strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNo)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.HasPrefix(value, `"`):
+			// Join following lines until the closing quote.
+			end := closingQuote(value)
+			for end < 0 && i+1 < len(lines) {
+				i++
+				value += "\n" + lines[i]
+				end = closingQuote(value)
+			}
+			if end < 0 {
+				return fmt.Errorf("%s:%d: unterminated quoted value", path, lineNo)
+			}
+			value = unescapeEnv(value[1:end])
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'") + 1
+			if end == 0 {
+				return fmt.Errorf("%s:%d: unterminated quoted value", path, lineNo)
+			}
+			value = value[1:end]
+		default:
+			if j := strings.Index(value, " #"); j >= 0 {
+				value = strings.TrimSpace(value[:j])
+			}
+		}
+		vars[key] = value
+	}
+	return nil
+}
+
+// closingQuote returns the index of the unescaped quote closing the
+// double-quoted value s, or -1.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func unescapeEnv(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\', '$':
+				b.WriteByte(s[i])
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// declaredEnv returns, sorted, the resolved KEY=VALUE pairs of just the
+// variables the task file sets for t, leaving out the inherited ones.
+func (ts *TaskSet) declaredEnv(t *Task) []string {
+	env := environMap(ts.taskEnv(t))
+	declared := map[string]string{}
+	for k := range ts.Env {
+		declared[k] = env[k]
+	}
+	for k := range t.Env {
+		declared[k] = env[k]
+	}
+	return environList(declared)
+}
+
+// applyEnv expands every value of layer against env as it was before the
+// layer, then merges the layer into env.
+func applyEnv(env, layer map[string]string) {
+	expanded := make(map[string]string, len(layer))
+	for k, v := range layer {
+		expanded[k] = os.Expand(v, func(name string) string { return env[name] })
+	}
+	for k, v := range expanded {
+		env[k] = v
+	}
+}
+
+func environMap(environ []string) map[string]string {
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+	return env
+}
+
+func environList(env map[string]string) []string {
+	list := make([]string, 0, len(env))
+	for k, v := range env {
+		list = append(list, k+"="+v)
+	}
+	sort.Strings(list)
+	return list
+}
@@ -0,0 +1,96 @@
+package zr
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskEnvLayering(t *testing.T) {
+	t.Setenv("ZR_TEST_BASE", "/usr/bin")
+	t.Setenv("ZR_TEST_KEEP", "inherited")
+	ts := writeTaskFile(t, `
+env:
+  ZR_TEST_BASE: $ZR_TEST_BASE:/opt/bin
+  ZR_TEST_MODE: global
+tasks:
+  build:
+    env:
+      ZR_TEST_MODE: task
+      ZR_TEST_PATH: ${ZR_TEST_BASE}:/task/bin
+      CGO_ENABLED: "0"
+  test: {}
+`)
+	build := environMap(ts.taskEnv(ts.Tasks["build"]))
+	assert.Equal(t, "inherited", build["ZR_TEST_KEEP"])
+	assert.Equal(t, "/usr/bin:/opt/bin", build["ZR_TEST_BASE"])
+	assert.Equal(t, "task", build["ZR_TEST_MODE"])
+	assert.Equal(t, "/usr/bin:/opt/bin:/task/bin", build["ZR_TEST_PATH"])
+	assert.Equal(t, "0", build["CGO_ENABLED"])
+
+	test := environMap(ts.taskEnv(ts.Tasks["test"]))
+	assert.Equal(t, "global", test["ZR_TEST_MODE"])
+	assert.NotContains(t, test, "CGO_ENABLED")
+}
+
+func TestParseEnvFile(t *testing.T) {
+	vars := map[string]string{}
+	err := parseEnvFile(".env", `# comment
+PLAIN=value # trailing comment
+export EXPORTED=yes
+SPACED = padded
+DOUBLE="line one\nsays \"hi\" # not a comment"
+SINGLE='$HOME\n stays'
+MULTI="first
+second"
+EMPTY=
+`, vars)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"PLAIN":    "value",
+		"EXPORTED": "yes",
+		"SPACED":   "padded",
+		"DOUBLE":   "line one\nsays \"hi\" # not a comment",
+		"SINGLE":   `$HOME\n stays`,
+		"MULTI":    "first\nsecond",
+		"EMPTY":    "",
+	}, vars)
+
+	assert.EqualError(t, parseEnvFile(".env", "A=1\nnot a pair\n", vars), ".env:2: expected KEY=VALUE")
+	assert.EqualError(t, parseEnvFile(".env", `A="open`, vars), ".env:1: unterminated quoted value")
+}
+
+func TestEnvFileLayering(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"zr.yaml": `
+env_file: [.env, ".env.local?"]
+env:
+  MODE: $FROM_FILE-global
+tasks:
+  build:
+    env_file: build.env
+    env: {LEVEL: explicit}
+`,
+		".env":      "FROM_FILE=file\nMODE=file\nSECRET=$ecret\n",
+		"build.env": "LEVEL=file\nTASK_ONLY=1\n",
+	})
+	ts, err := LoadTasks(filepath.Join(root, "zr.yaml"))
+	require.NoError(t, err)
+	env := environMap(ts.taskEnv(ts.Tasks["build"]))
+	assert.Equal(t, "file-global", env["MODE"], "env overrides env_file and may reference it")
+	assert.Equal(t, "$ecret", env["SECRET"], "env file values are literal")
+	assert.Equal(t, "explicit", env["LEVEL"])
+	assert.Equal(t, "1", env["TASK_ONLY"])
+}
+
+func TestEnvFileMissing(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"zr.yaml": "tasks:\n  build: {env_file: missing.env}\n",
+	})
+	_, err := LoadTasks(filepath.Join(root, "zr.yaml"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `task "build": env_file:`)
+	assert.Contains(t, err.Error(), "missing.env")
+}
@@ -0,0 +1,925 @@
+package zr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// RunOptions configures how a plan is executed. The zero value runs up to
+// runtime.NumCPU() tasks at once, writing to os.Stdout and os.Stderr, and
+// stops scheduling at the first failure.
+type RunOptions struct {
+	// Stdout and Stderr receive the output of zr and of task commands.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Jobs caps how many tasks run at once. Zero means runtime.NumCPU()
+	// and a negative value means no cap.
+	Jobs int
+
+	// Only runs just the named tasks, ignoring their dependencies. It only
+	// applies to Run.
+	Only bool
+
+	// From resumes the run at the named task, skipping the planned tasks
+	// that don't depend on it; see StartFrom. It only applies to Run.
+	From string
+
+	// KeepGoing keeps scheduling tasks whose dependencies succeeded after
+	// another task has failed; only the descendants of failed tasks are
+	// skipped.
+	KeepGoing bool
+
+	// Prefix labels every line of task output with the task name.
+	Prefix bool
+
+	// Verbose echoes each command and its environment before running it.
+	Verbose bool
+
+	// Force runs every task, ignoring freshness and cache hits. Successful
+	// runs still record cache entries.
+	Force bool
+
+	// Remote, if set, is checked for the outputs of cacheable tasks that
+	// miss the local cache, and receives the outputs of those that run
+	// successfully. Problems reaching it are only warnings.
+	Remote RemoteCache
+
+	// LogDir, if set, receives a copy of the output of every task that
+	// runs, in a file named after the task. Each run first removes the
+	// files of all planned tasks, so skipped ones are left without one.
+	LogDir string
+
+	// Confirm is called, one task at a time, before running a task with a
+	// confirm question and reports whether to go ahead. Tasks it declines
+	// fail with ErrNotConfirmed, and if it is nil every such task does.
+	Confirm func(t *Task) (bool, error)
+}
+
+// ErrNotConfirmed is the error of a task whose confirm question wasn't
+// answered yes.
+var ErrNotConfirmed = errors.New("not confirmed")
+
+// Status is the outcome of a planned task.
+type Status int
+
+const (
+	StatusPending Status = iota
+	StatusSucceeded
+	StatusFailed
+	// StatusSkipped is for tasks that never started because a dependency
+	// failed or the run stopped early.
+	StatusSkipped
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusSucceeded:
+		return "succeeded"
+	case StatusFailed:
+		return "failed"
+	case StatusSkipped:
+		return "skipped"
+	default:
+		return "pending"
+	}
+}
+
+// TaskResult is the outcome of one planned task.
+type TaskResult struct {
+	Task   *Task
+	Status Status
+	// Err is why the task failed.
+	Err error
+
+	// Start, Duration and CPU are only set for tasks that started. CPU is
+	// the user and system time of the task's processes.
+	Start    time.Time
+	Duration time.Duration
+	CPU      time.Duration
+
+	// SkipReason says why a task succeeded without running its commands:
+	// "up to date", "cached" or "not for" followed by the operating system.
+	SkipReason string
+
+	// LogFile is the path the task's output was copied to, with
+	// RunOptions.LogDir.
+	LogFile string
+}
+
+// Started reports whether the task was started.
+func (r TaskResult) Started() bool {
+	return r.Status == StatusSucceeded || r.Status == StatusFailed
+}
+
+// Result is the outcome of a run.
+type Result struct {
+	// Tasks holds every planned task, in plan order.
+	Tasks []TaskResult
+	// Duration is the wall-clock time of the run.
+	Duration time.Duration
+}
+
+// Failed returns the results of the tasks that failed.
+func (r *Result) Failed() []TaskResult {
+	var failed []TaskResult
+	for _, tr := range r.Tasks {
+		if tr.Status == StatusFailed {
+			failed = append(failed, tr)
+		}
+	}
+	return failed
+}
+
+// Run plans names and their dependencies, or only names with opts.Only,
+// optionally resumed at opts.From, and executes the plan.
+func (ts *TaskSet) Run(ctx context.Context, names []string, opts RunOptions) (*Result, error) {
+	plan, err := ts.Plan(names)
+	if opts.Only {
+		plan, err = ts.PlanOnly(names)
+	}
+	if err == nil && opts.From != "" {
+		plan, err = ts.StartFrom(plan, opts.From)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ts.Execute(ctx, plan, opts)
+}
+
+// Execute runs the tasks of plan as shell subprocesses. Rather than waiting
+// for a whole level to finish, each task starts as soon as all of its
+// dependencies have succeeded and the planned tasks it runs after have
+// finished or can no longer start. After the first failure no new tasks
+// are started unless opts.KeepGoing is set. Tasks already running are
+// always allowed to finish, and cancelling ctx stops them.
+//
+// The Result covers every planned task and is returned even when the run
+// fails.
+func (ts *TaskSet) Execute(ctx context.Context, plan Plan, opts RunOptions) (*Result, error) {
+	return newExecutor(ts, opts).run(ctx, plan)
+}
+
+// executor runs planned tasks as shell subprocesses.
+type executor struct {
+	ts     *TaskSet
+	stdout io.Writer
+	stderr io.Writer
+
+	keepGoing bool
+	// jobs caps how many tasks run at once; zero or less means no cap.
+	jobs   int
+	force  bool
+	cache  *Cache
+	remote RemoteCache
+
+	confirm   func(t *Task) (bool, error)
+	confirmMu sync.Mutex
+
+	verbose bool
+	prefix  bool
+	outMu   sync.Mutex
+
+	// cpu accumulates the user and system time of each task's processes,
+	// and skipped records why tasks didn't need to run.
+	statsMu sync.Mutex
+	cpu     map[string]time.Duration
+	skipped map[string]string
+
+	// needed holds the tasks another planned task depends on, which are
+	// skipped without a note when they aren't for this system.
+	needed map[string]bool
+
+	// logs holds the log file of every task that ran with a logDir.
+	logDir string
+	logs   map[string]*logFile
+}
+
+func newExecutor(ts *TaskSet, opts RunOptions) *executor {
+	e := &executor{
+		ts:        ts,
+		stdout:    opts.Stdout,
+		stderr:    opts.Stderr,
+		keepGoing: opts.KeepGoing,
+		jobs:      opts.Jobs,
+		force:     opts.Force,
+		confirm:   opts.Confirm,
+		cache:     ts.Cache(),
+		remote:    opts.Remote,
+		verbose:   opts.Verbose,
+		prefix:    opts.Prefix,
+		cpu:       map[string]time.Duration{},
+		skipped:   map[string]string{},
+		logDir:    opts.LogDir,
+		logs:      map[string]*logFile{},
+		needed:    map[string]bool{},
+	}
+	if e.stdout == nil {
+		e.stdout = os.Stdout
+	}
+	if e.stderr == nil {
+		e.stderr = os.Stderr
+	}
+	// Files are safe to write at the same time and are handed to commands
+	// as they are, so those still see a terminal; anything else is only
+	// written to under outMu.
+	if _, ok := e.stdout.(*os.File); !ok {
+		e.stdout = &syncWriter{mu: &e.outMu, w: e.stdout}
+	}
+	if _, ok := e.stderr.(*os.File); !ok {
+		e.stderr = &syncWriter{mu: &e.outMu, w: e.stderr}
+	}
+	if e.jobs == 0 {
+		e.jobs = runtime.NumCPU()
+	}
+	return e
+}
+
+// finishedTask is sent by a task's goroutine when it is done.
+type finishedTask struct {
+	task     *Task
+	err      error
+	start    time.Time
+	duration time.Duration
+}
+
+func (e *executor) run(ctx context.Context, plan Plan) (*Result, error) {
+	waiting := map[string]int{}
+	// dependents need a task to succeed, followers only wait for it to be
+	// over.
+	dependents := map[string][]*Task{}
+	followers := map[string][]*Task{}
+	status := map[string]Status{}
+	for _, tasks := range plan {
+		for _, t := range tasks {
+			status[t.Name] = StatusPending
+		}
+	}
+	// Dependencies left out of the plan, by RunOptions.Only or From, are
+	// treated as already satisfied.
+	var ready []*Task
+	for _, tasks := range plan {
+		for _, t := range tasks {
+			for _, dep := range t.Deps {
+				if _, ok := status[dep]; ok {
+					e.needed[dep] = true
+					waiting[t.Name]++
+					dependents[dep] = append(dependents[dep], t)
+				}
+			}
+			for _, prev := range t.RunAfter {
+				if _, ok := status[prev]; ok {
+					waiting[t.Name]++
+					followers[prev] = append(followers[prev], t)
+				}
+			}
+			if waiting[t.Name] == 0 {
+				ready = append(ready, t)
+			}
+		}
+	}
+	if e.logDir != "" {
+		e.clearLogs(plan)
+	}
+
+	runStart := time.Now()
+	finished := make(chan finishedTask)
+	done := map[string]finishedTask{}
+	running := 0
+	// startReady launches queued tasks while fewer than jobs are running.
+	// The cap spans the whole run rather than each level, and with one job
+	// tasks run serially in the order they became ready. Nothing new starts
+	// once ctx is cancelled.
+	startReady := func() {
+		for len(ready) > 0 && ctx.Err() == nil && (e.jobs <= 0 || running < e.jobs) {
+			t := ready[0]
+			ready = ready[1:]
+			running++
+			go func() {
+				start := time.Now()
+				err := e.runTask(ctx, t)
+				finished <- finishedTask{task: t, err: err, start: start, duration: time.Since(start)}
+			}()
+		}
+	}
+	startReady()
+
+	release := func(tasks []*Task) {
+		for _, d := range tasks {
+			waiting[d.Name]--
+			if waiting[d.Name] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+	// abandon releases the followers of a failed task and of every task
+	// that, depending on it, will now never start.
+	abandoned := map[string]bool{}
+	var abandon func(name string)
+	abandon = func(name string) {
+		release(followers[name])
+		for _, d := range dependents[name] {
+			if !abandoned[d.Name] {
+				abandoned[d.Name] = true
+				abandon(d.Name)
+			}
+		}
+	}
+
+	var firstErr error
+	failed := 0
+	for running > 0 {
+		f := <-finished
+		running--
+		done[f.task.Name] = f
+		if f.err != nil {
+			status[f.task.Name] = StatusFailed
+			failed++
+			if firstErr == nil {
+				firstErr = fmt.Errorf("task %q failed: %w", f.task.Name, f.err)
+			}
+			if e.keepGoing {
+				abandon(f.task.Name)
+			} else {
+				ready = nil
+			}
+		} else {
+			status[f.task.Name] = StatusSucceeded
+			if firstErr == nil || e.keepGoing {
+				release(dependents[f.task.Name])
+				release(followers[f.task.Name])
+			}
+		}
+		startReady()
+	}
+
+	res := &Result{Duration: time.Since(runStart)}
+	e.statsMu.Lock()
+	for _, tasks := range plan {
+		for _, t := range tasks {
+			tr := TaskResult{Task: t, Status: status[t.Name]}
+			if tr.Status == StatusPending {
+				tr.Status = StatusSkipped
+			}
+			if f, ok := done[t.Name]; ok {
+				tr.Err, tr.Start, tr.Duration = f.err, f.start, f.duration
+				tr.CPU = e.cpu[t.Name]
+				tr.SkipReason = e.skipped[t.Name]
+				if l, ok := e.logs[t.Name]; ok {
+					tr.LogFile = l.path
+				}
+			}
+			res.Tasks = append(res.Tasks, tr)
+		}
+	}
+	e.statsMu.Unlock()
+
+	if e.keepGoing && failed > 0 {
+		return res, &failuresError{failed: failed, total: len(status), first: firstErr}
+	}
+	return res, firstErr
+}
+
+// failuresError is returned when tasks failed in a run that kept going. It
+// unwraps to the error of the task that failed first, so callers can still
+// find, say, its exit code.
+type failuresError struct {
+	failed, total int
+	first         error
+}
+
+func (e *failuresError) Error() string {
+	return fmt.Sprintf("%d of %d tasks failed", e.failed, e.total)
+}
+
+func (e *failuresError) Unwrap() error {
+	return e.first
+}
+
+// runTask runs a single task's command. Tasks without a command only group
+// their dependencies and succeed immediately. Tasks whose outputs are newer
+// than their inputs, or whose cache key matches an earlier successful run
+// here or in the remote cache, are skipped but still count as satisfied,
+// as are tasks for other operating systems. Tasks with a confirm question
+// only run once it is answered yes, and tasks with a lock once they hold
+// it.
+func (e *executor) runTask(ctx context.Context, t *Task) error {
+	if !t.RunsOn(runtime.GOOS) {
+		logger.Debug("skipping task for other systems", "task", t.Name, "os", t.OS)
+		reason := "not for " + runtime.GOOS
+		if !e.needed[t.Name] {
+			fmt.Fprintf(e.stdout, "%s: %s (skipped)\n", t.Name, reason)
+		}
+		e.skip(t, reason)
+		return nil
+	}
+	fresh, err := upToDate(e.ts.Dir(), t)
+	if err != nil {
+		return err
+	}
+	logger.Debug("checked freshness", "task", t.Name, "up_to_date", fresh, "force", e.force)
+	if fresh && !e.force {
+		fmt.Fprintf(e.stdout, "%s: up to date (skipped)\n", t.Name)
+		e.skip(t, "up to date")
+		return nil
+	}
+	var key string
+	if t.Cacheable() {
+		if key, err = e.ts.cacheKey(t); err != nil {
+			return err
+		}
+		hit, err := e.cached(t, key)
+		if err != nil {
+			return err
+		}
+		logger.Debug("looked up cache", "task", t.Name, "key", key, "hit", hit)
+		if hit && !e.force {
+			fmt.Fprintf(e.stdout, "%s: cached (skipped)\n", t.Name)
+			e.skip(t, "cached")
+			return nil
+		}
+		if e.remote != nil && !e.force {
+			restored, err := e.restoreRemote(ctx, t, key)
+			logger.Debug("looked up remote cache", "task", t.Name, "key", key, "hit", restored)
+			if err != nil {
+				fmt.Fprintf(e.stderr, "%s: remote cache: %v (warning)\n", t.Name, err)
+			}
+			if restored && err == nil {
+				fmt.Fprintf(e.stdout, "%s: restored from remote cache (skipped)\n", t.Name)
+				e.skip(t, "cached")
+				return e.cache.record(CacheEntry{Task: t.Name, Key: key, Created: time.Now()})
+			}
+		}
+	}
+	if !t.Silent {
+		fmt.Fprintln(e.stdout, colorize(colorCyan, "==> "+t.Name))
+	}
+	if t.Confirm != "" {
+		if err := e.confirmed(t); err != nil {
+			return err
+		}
+	}
+	if t.Lock != "" {
+		lock, err := acquireLock(ctx, e.ts.lockPath(t.Lock), func() {
+			fmt.Fprintf(e.stdout, "%s: waiting for lock %q...\n", t.Name, t.Lock)
+		})
+		if err != nil {
+			return err
+		}
+		defer lock.release()
+	}
+	if e.logDir != "" && len(t.Before)+len(t.Cmd)+len(t.After) > 0 {
+		l, err := e.openLog(t)
+		if err != nil {
+			return err
+		}
+		defer l.f.Close()
+	}
+	if e.verbose && !t.Silent && len(t.Before)+len(t.Cmd)+len(t.After) > 0 {
+		e.echoEnv(t)
+	}
+	if err := e.runHooked(ctx, t); err != nil {
+		return err
+	}
+	if key == "" {
+		return nil
+	}
+	if e.remote != nil {
+		if err := e.storeRemote(ctx, t, key); err != nil {
+			fmt.Fprintf(e.stderr, "%s: remote cache: %v (warning)\n", t.Name, err)
+		}
+	}
+	return e.cache.record(CacheEntry{Task: t.Name, Key: key, Created: time.Now()})
+}
+
+// confirmed asks whether to run t, holding the other tasks' questions back
+// until it is answered.
+func (e *executor) confirmed(t *Task) error {
+	if e.confirm == nil {
+		return ErrNotConfirmed
+	}
+	e.confirmMu.Lock()
+	defer e.confirmMu.Unlock()
+	ok, err := e.confirm(t)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotConfirmed
+	}
+	return nil
+}
+
+// logFile is the log of a task, shared by the stdout and stderr of all of
+// its commands.
+type logFile struct {
+	path string
+	mu   sync.Mutex
+	f    *os.File
+}
+
+func (l *logFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Write(p)
+}
+
+// clearLogs removes the log files a previous run left for the tasks of
+// plan, so tasks skipped this time don't keep output that is no longer
+// current.
+func (e *executor) clearLogs(plan Plan) {
+	for _, tasks := range plan {
+		for _, t := range tasks {
+			err := os.Remove(filepath.Join(e.logDir, logFileName(t.Name)))
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				fmt.Fprintf(e.stderr, "%s: %v (warning)\n", t.Name, err)
+			}
+		}
+	}
+}
+
+// openLog creates or truncates the log file of t in the log directory.
+func (e *executor) openLog(t *Task) (*logFile, error) {
+	if err := os.MkdirAll(e.logDir, 0o755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(e.logDir, logFileName(t.Name))
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	l := &logFile{path: path, f: f}
+	e.statsMu.Lock()
+	e.logs[t.Name] = l
+	e.statsMu.Unlock()
+	return l, nil
+}
+
+// logOf returns the open log file of t, or nil without a log directory.
+func (e *executor) logOf(t *Task) *logFile {
+	e.statsMu.Lock()
+	defer e.statsMu.Unlock()
+	return e.logs[t.Name]
+}
+
+// logFileName turns a task name into a file name that is valid on every
+// platform, replacing characters such as the colon of matrix tasks.
+func logFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name) + ".log"
+}
+
+// skip records that t didn't need to run and why.
+func (e *executor) skip(t *Task, reason string) {
+	e.statsMu.Lock()
+	e.skipped[t.Name] = reason
+	e.statsMu.Unlock()
+}
+
+// cached reports whether a successful run of t with key is on record and
+// its outputs are still present.
+func (e *executor) cached(t *Task, key string) (bool, error) {
+	hit, err := e.cache.lookup(key)
+	if err != nil || !hit {
+		return false, err
+	}
+	_, ok, err := existingOutputs(e.ts.Dir(), t)
+	return ok, err
+}
+
+// echoEnv prints the environment the task file sets for t, ahead of its
+// commands, which spawn echoes as each one starts.
+func (e *executor) echoEnv(t *Task) {
+	for _, kv := range e.ts.declaredEnv(t) {
+		fmt.Fprintln(e.stdout, colorize(colorDim, "["+t.Name+"]   "+kv))
+	}
+	if t.Nice != 0 && !niceSupported {
+		fmt.Fprintln(e.stdout, colorize(colorYellow, "["+t.Name+"] nice is not supported on "+runtime.GOOS+", ignoring it"))
+	}
+}
+
+// runHooked runs the before commands of t, its commands and its after
+// commands. The after commands run however the others went, even once ctx
+// is cancelled, so they can undo what the before commands set up. Their
+// failure is reported as a warning and doesn't change the task's outcome.
+// The before commands and commands share the task's timeout, and the after
+// commands get one of their own.
+func (e *executor) runHooked(ctx context.Context, t *Task) error {
+	timer := newTaskTimer(ctx, t.Timeout)
+	err := e.runHook(timer.ctx, t, "before", t.Before)
+	if err == nil {
+		err = e.runCommands(timer, t)
+	}
+	timer.stop()
+
+	after := newTaskTimer(context.WithoutCancel(ctx), t.Timeout)
+	defer after.stop()
+	if afterErr := e.runHook(after.ctx, t, "after", t.After); afterErr != nil {
+		fmt.Fprintf(e.stderr, "%s: %v (warning)\n", t.Name, afterErr)
+	}
+	return err
+}
+
+// taskTimer bounds commands of a task by its timeout. Its ctx is cancelled
+// with a *timeoutError as the cause once the timeout elapses.
+type taskTimer struct {
+	parent  context.Context
+	timeout time.Duration
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+func newTaskTimer(parent context.Context, timeout time.Duration) *taskTimer {
+	timer := &taskTimer{parent: parent, timeout: timeout}
+	timer.restart()
+	return timer
+}
+
+// restart starts the timeout over, replacing ctx.
+func (timer *taskTimer) restart() {
+	if timer.cancel != nil {
+		timer.cancel()
+	}
+	if timer.timeout <= 0 {
+		timer.ctx, timer.cancel = context.WithCancel(timer.parent)
+		return
+	}
+	timer.ctx, timer.cancel = context.WithTimeoutCause(timer.parent, timer.timeout, &timeoutError{timeout: timer.timeout})
+}
+
+func (timer *taskTimer) stop() {
+	timer.cancel()
+}
+
+// runHook runs the before or after commands of t, stopping at the first
+// that fails. Hooks are never retried.
+func (e *executor) runHook(ctx context.Context, t *Task, hook string, cmds CommandList) error {
+	for i, cmd := range cmds {
+		if err := e.spawn(ctx, t, hook, cmd); err != nil {
+			return fmt.Errorf("%s command %d/%d (%s): %w", hook, i+1, len(cmds), firstLine(cmd), err)
+		}
+	}
+	return nil
+}
+
+// runCommands runs the commands of t in order, stopping at the first that
+// fails unless t.IgnoreErrors is set. Errors of tasks with several commands
+// say which one failed.
+func (e *executor) runCommands(timer *taskTimer, t *Task) error {
+	for i, cmd := range t.Cmd {
+		err := e.spawnRetrying(timer, t, cmd)
+		if err == nil {
+			continue
+		}
+		if len(t.Cmd) > 1 {
+			err = fmt.Errorf("command %d/%d (%s): %w", i+1, len(t.Cmd), firstLine(cmd), err)
+		}
+		if !t.IgnoreErrors || timer.ctx.Err() != nil {
+			return err
+		}
+		fmt.Fprintf(e.stderr, "%s: %v (ignored)\n", t.Name, err)
+	}
+	return nil
+}
+
+func firstLine(s string) string {
+	line, _, more := strings.Cut(strings.TrimSpace(s), "\n")
+	if more {
+		line += " ..."
+	}
+	return line
+}
+
+// spawnRetrying runs cmd for t, retrying a failed attempt up to t.Retries
+// more times with t.RetryDelay in between. Only non-zero exits are retried,
+// and timeouts when t.RetryOnTimeout is set, restarting the timer; a
+// cancelled run never is.
+func (e *executor) spawnRetrying(timer *taskTimer, t *Task, cmd string) error {
+	for attempt := 1; ; attempt++ {
+		err := e.spawn(timer.ctx, t, "", cmd)
+		if err == nil || attempt > t.Retries || !retryable(t, err) {
+			return err
+		}
+		fmt.Fprintf(e.stdout, "%s: retry %d/%d\n", t.Name, attempt, t.Retries)
+		select {
+		case <-time.After(t.RetryDelay):
+		case <-timer.parent.Done():
+			return err
+		}
+		var timeout *timeoutError
+		if errors.As(err, &timeout) {
+			timer.restart()
+		}
+	}
+}
+
+func retryable(t *Task, err error) bool {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return true
+	}
+	var timeout *timeoutError
+	return errors.As(err, &timeout) && t.RetryOnTimeout
+}
+
+// spawn runs cmd, one of the commands of t or of its before or after hook,
+// as a subprocess. With verbose output it first echoes cmd, labelled with
+// the hook it belongs to.
+func (e *executor) spawn(ctx context.Context, t *Task, hook, cmd string) error {
+	dir, err := e.ts.workDir(t)
+	if err != nil {
+		return err
+	}
+	if e.verbose && !t.Silent {
+		label := "[" + t.Name + "] "
+		if hook != "" {
+			label += hook + " "
+		}
+		for _, line := range (CommandList{cmd}).Lines() {
+			fmt.Fprintln(e.stdout, colorize(colorDim, label+"$ "+line))
+		}
+	}
+	c := shellCommand(e.ts.shellFor(t), cmd)
+	c.Dir = dir
+	c.Env = e.ts.taskEnv(t)
+	c.Stdout = e.stdout
+	c.Stderr = e.stderr
+	if e.prefix {
+		// stdout and stderr stay on their own streams; stderr lines get the
+		// task name in red so they stand out when both reach a terminal.
+		label := "[" + t.Name + "] "
+		stdout := &linePrefixer{w: e.stdout, prefix: colorize(taskColor(t.Name), label)}
+		stderr := &linePrefixer{w: e.stderr, prefix: colorize(colorRed, label)}
+		defer stdout.Flush()
+		defer stderr.Flush()
+		c.Stdout = stdout
+		c.Stderr = stderr
+	}
+	if l := e.logOf(t); l != nil {
+		// The log gets the output as the command wrote it, unprefixed.
+		c.Stdout = io.MultiWriter(c.Stdout, l)
+		c.Stderr = io.MultiWriter(c.Stderr, l)
+	}
+	err = runProcess(ctx, c, t.Nice)
+	if c.Process != nil {
+		logger.Debug("process exited", "task", t.Name, "pid", c.Process.Pid, "err", err)
+	}
+	if c.ProcessState != nil {
+		e.statsMu.Lock()
+		e.cpu[t.Name] += c.ProcessState.UserTime() + c.ProcessState.SystemTime()
+		e.statsMu.Unlock()
+	}
+	return err
+}
+
+// defaultShell is the interpreter used when neither the task nor the task
+// file picks one.
+func defaultShell() string {
+	if runtime.GOOS == "windows" {
+		return "cmd"
+	}
+	return "sh"
+}
+
+// shellName returns the lower-cased base name of shell without an .exe
+// suffix. Both separators are split on so Windows paths are recognized
+// everywhere.
+func shellName(shell string) string {
+	name := strings.ToLower(shell[strings.LastIndexAny(shell, `/\`)+1:])
+	return strings.TrimSuffix(name, ".exe")
+}
+
+// shellCommand runs script with shell, passing it through the flag that
+// interpreter expects: /C for cmd, -Command for PowerShell and -c for
+// POSIX-style shells.
+func shellCommand(shell, script string) *exec.Cmd {
+	switch shellName(shell) {
+	case "cmd":
+		return exec.Command(shell, "/C", script)
+	case "pwsh", "powershell":
+		return exec.Command(shell, "-NoProfile", "-Command", script)
+	default:
+		return exec.Command(shell, "-c", script)
+	}
+}
+
+// safeArg matches arguments no shell needs quoted.
+var safeArg = regexp.MustCompile(`^[A-Za-z0-9_./=:@%+,-]+$`)
+
+// shellQuote quotes arg so shell passes it through as a single argument.
+func shellQuote(shell, arg string) string {
+	if safeArg.MatchString(arg) {
+		return arg
+	}
+	switch shellName(shell) {
+	case "cmd":
+		return `"` + strings.ReplaceAll(arg, `"`, `""`) + `"`
+	case "pwsh", "powershell":
+		return "'" + strings.ReplaceAll(arg, "'", "''") + "'"
+	default:
+		return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+}
+
+// killGrace is how long a terminated process group gets to exit before it
+// is killed.
+var killGrace = 5 * time.Second
+
+// timeoutError reports a task that was stopped for exceeding its timeout.
+type timeoutError struct {
+	timeout time.Duration
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s", e.timeout)
+}
+
+// runProcess starts c in its own process group, at niceness nice unless
+// it is zero, and waits for it. If ctx is done first, the whole group is
+// stopped, so processes spawned by the shell are cleaned up too, and the
+// cause of ctx, such as a *timeoutError, is returned.
+func runProcess(ctx context.Context, c *exec.Cmd, nice int) error {
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	if nice != 0 && niceSupported {
+		if err := setNice(c, nice); err != nil {
+			return fmt.Errorf("nice %d: %w", nice, err)
+		}
+	}
+	setProcessGroup(c)
+	if err := c.Start(); err != nil {
+		return err
+	}
+	trackProcess(c)
+	defer untrackProcess(c)
+	logger.Debug("started process", "pid", c.Process.Pid, "dir", c.Dir, "args", c.Args)
+	done := make(chan error, 1)
+	go func() { done <- c.Wait() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		stopProcess(c, done)
+		return context.Cause(ctx)
+	}
+}
+
+// stopProcess asks c's process group to terminate, then kills it if it is
+// still running after killGrace.
+func stopProcess(c *exec.Cmd, done <-chan error) {
+	terminateGroup(c)
+	select {
+	case <-done:
+	case <-time.After(killGrace):
+		killGroup(c)
+		<-done
+	}
+}
+
+// running tracks the task processes that have been started and not yet
+// waited for, so a forced exit doesn't leave them behind.
+var running = struct {
+	sync.Mutex
+	procs map[*exec.Cmd]bool
+}{procs: map[*exec.Cmd]bool{}}
+
+func trackProcess(c *exec.Cmd) {
+	running.Lock()
+	running.procs[c] = true
+	running.Unlock()
+}
+
+func untrackProcess(c *exec.Cmd) {
+	running.Lock()
+	delete(running.procs, c)
+	running.Unlock()
+}
+
+// KillRunning kills the process groups of all task commands that are
+// still running, in this and every other run. It is meant for a forced
+// exit, where there is no time to stop them gracefully.
+func KillRunning() {
+	running.Lock()
+	defer running.Unlock()
+	for c := range running.procs {
+		killGroup(c)
+	}
+}
@@ -0,0 +1,392 @@
+package zr
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunInDependencyOrder(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  build: {cmd: echo build >> order}
+  test: {cmd: echo test >> order, deps: [build]}
+`)
+	_, err := ts.Run(context.Background(), []string{"test"}, RunOptions{Stdout: &bytes.Buffer{}})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(ts.Dir(), "order"))
+	require.NoError(t, err)
+	assert.Equal(t, "build\ntest\n", string(data))
+}
+
+func TestRunKeepGoing(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  broken: {cmd: exit 3}
+  deploy: {cmd: touch deployed, deps: [broken]}
+  lint: {cmd: touch linted}
+`)
+	res, err := ts.Run(context.Background(), []string{"deploy", "lint"},
+		RunOptions{Stdout: &bytes.Buffer{}, KeepGoing: true})
+	assert.EqualError(t, err, "1 of 3 tasks failed")
+
+	assert.FileExists(t, filepath.Join(ts.Dir(), "linted"))
+	assert.NoFileExists(t, filepath.Join(ts.Dir(), "deployed"))
+	status := map[string]Status{}
+	for _, tr := range res.Tasks {
+		status[tr.Task.Name] = tr.Status
+	}
+	assert.Equal(t, map[string]Status{"broken": StatusFailed, "deploy": StatusSkipped, "lint": StatusSucceeded}, status)
+	if assert.Len(t, res.Failed(), 1) {
+		assert.EqualError(t, res.Failed()[0].Err, "exit status 3")
+	}
+}
+
+func TestRunAfterOrdersWithoutRequiring(t *testing.T) {
+	// Each task logs when it starts and ends, taking long enough for
+	// overlapping tasks to interleave.
+	ts := writeTaskFile(t, `
+tasks:
+  db-up: {cmd: "echo start db-up >> log; sleep 0.1; echo end db-up >> log; test ! -f db-broken"}
+  migrate: {cmd: "echo start migrate >> log; echo end migrate >> log", run_after: [db-up]}
+  lint-a: {cmd: "echo start lint-a >> log; sleep 0.1; echo end lint-a >> log"}
+  lint-b: {cmd: "echo start lint-b >> log; sleep 0.1; echo end lint-b >> log"}
+`)
+	logLines := func() []string {
+		data, err := os.ReadFile(filepath.Join(ts.Dir(), "log"))
+		require.NoError(t, err)
+		os.Remove(filepath.Join(ts.Dir(), "log"))
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+	all := []string{"migrate", "db-up", "lint-a", "lint-b"}
+
+	for _, jobs := range []int{1, 4} {
+		_, err := ts.Run(context.Background(), all, RunOptions{Stdout: io.Discard, Jobs: jobs})
+		require.NoError(t, err)
+		lines := logLines()
+		assert.Less(t, slices.Index(lines, "end db-up"), slices.Index(lines, "start migrate"), "jobs %d", jobs)
+		// The linters are unordered, so with enough jobs they overlap.
+		overlap := slices.Index(lines, "start lint-b") < slices.Index(lines, "end lint-a") &&
+			slices.Index(lines, "start lint-a") < slices.Index(lines, "end lint-b")
+		assert.Equal(t, jobs > 1, overlap, "jobs %d", jobs)
+	}
+
+	_, err := ts.Run(context.Background(), []string{"migrate"}, RunOptions{Stdout: &bytes.Buffer{}, Jobs: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"start migrate", "end migrate"}, logLines(), "run_after doesn't pull db-up in")
+
+	// Ordering doesn't need success: migrate still runs after db-up fails.
+	require.NoError(t, os.WriteFile(filepath.Join(ts.Dir(), "db-broken"), nil, 0o644))
+	_, err = ts.Run(context.Background(), []string{"db-up", "migrate"}, RunOptions{Stdout: &bytes.Buffer{}, KeepGoing: true})
+	assert.EqualError(t, err, "1 of 2 tasks failed")
+	assert.Equal(t, []string{"start db-up", "end db-up", "start migrate", "end migrate"}, logLines())
+}
+
+func TestRunAfterTaskThatCannotStart(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  broken: {cmd: exit 1}
+  deploy: {cmd: touch deployed, deps: [broken]}
+  notify: {cmd: touch notified, run_after: [deploy]}
+`)
+	res, err := ts.Run(context.Background(), []string{"deploy", "notify"}, RunOptions{Stdout: &bytes.Buffer{}, KeepGoing: true})
+	assert.EqualError(t, err, "1 of 3 tasks failed")
+	assert.FileExists(t, filepath.Join(ts.Dir(), "notified"), "deploy can never start, so notify stops waiting")
+	status := map[string]Status{}
+	for _, tr := range res.Tasks {
+		status[tr.Task.Name] = tr.Status
+	}
+	assert.Equal(t, map[string]Status{"broken": StatusFailed, "deploy": StatusSkipped, "notify": StatusSucceeded}, status)
+}
+
+func TestRunSkipsTasksForOtherSystems(t *testing.T) {
+	other := "plan9"
+	if runtime.GOOS == other {
+		other = "linux"
+	}
+	ts := writeTaskFile(t, `
+tasks:
+  setup: {cmd: touch set-up, os: [`+other+`]}
+  build: {cmd: touch built, deps: [setup], os: [`+runtime.GOOS+`, `+other+`]}
+  sign: {cmd: touch signed, os: [`+other+`]}
+`)
+	var out bytes.Buffer
+	res, err := ts.Run(context.Background(), []string{"build", "sign"}, RunOptions{Stdout: &out})
+	require.NoError(t, err)
+
+	assert.FileExists(t, filepath.Join(ts.Dir(), "built"))
+	assert.NoFileExists(t, filepath.Join(ts.Dir(), "set-up"))
+	assert.NoFileExists(t, filepath.Join(ts.Dir(), "signed"))
+	assert.Contains(t, out.String(), "sign: not for "+runtime.GOOS+" (skipped)\n")
+	assert.NotContains(t, out.String(), "setup:", "dependencies are skipped silently")
+	for _, tr := range res.Tasks {
+		assert.Equal(t, StatusSucceeded, tr.Status, tr.Task.Name)
+	}
+}
+
+func TestRunJobsCap(t *testing.T) {
+	// Each task records how many tasks were running when it started.
+	ts := writeTaskFile(t, `
+tasks:
+  a: {cmd: "mkdir -p run && touch run/a && ls run | wc -l >> peaks && sleep 0.1 && rm run/a"}
+  b: {cmd: "mkdir -p run && touch run/b && ls run | wc -l >> peaks && sleep 0.1 && rm run/b"}
+  c: {cmd: "mkdir -p run && touch run/c && ls run | wc -l >> peaks && sleep 0.1 && rm run/c", deps: [a]}
+  d: {cmd: "mkdir -p run && touch run/d && ls run | wc -l >> peaks && sleep 0.1 && rm run/d", deps: [b]}
+`)
+	var out bytes.Buffer
+	_, err := ts.Run(context.Background(), []string{"c", "d"}, RunOptions{Stdout: &out, Jobs: 1})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(ts.Dir(), "peaks"))
+	require.NoError(t, err)
+	assert.Equal(t, "1\n1\n1\n1\n", strings.ReplaceAll(string(data), " ", ""))
+
+	var order []string
+	for _, line := range strings.Split(stripColors(out.String()), "\n") {
+		if name, ok := strings.CutPrefix(line, "==> "); ok {
+			order = append(order, name)
+		}
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d"}, order)
+}
+
+func TestShellCommandFlags(t *testing.T) {
+	assert.Equal(t, []string{"bash", "-c", "echo hi"}, shellCommand("bash", "echo hi").Args)
+	assert.Equal(t, []string{"/bin/sh", "-c", "echo hi"}, shellCommand("/bin/sh", "echo hi").Args)
+	assert.Equal(t, []string{"cmd", "/C", "echo hi"}, shellCommand("cmd", "echo hi").Args)
+	assert.Equal(t, []string{`C:\Windows\System32\cmd.exe`, "/C", "echo hi"}, shellCommand(`C:\Windows\System32\cmd.exe`, "echo hi").Args[:3])
+	assert.Equal(t, []string{"pwsh", "-NoProfile", "-Command", "echo hi"}, shellCommand("pwsh", "echo hi").Args)
+}
+
+func TestShellFor(t *testing.T) {
+	ts := writeTaskFile(t, `
+shell: bash
+tasks:
+  build: {}
+  win: {shell: pwsh}
+`)
+	assert.Equal(t, "bash", ts.shellFor(ts.Tasks["build"]))
+	assert.Equal(t, "pwsh", ts.shellFor(ts.Tasks["win"]))
+	ts.Shell = ""
+	assert.Equal(t, defaultShell(), ts.shellFor(ts.Tasks["build"]))
+}
+
+func TestExecutorVerboseEcho(t *testing.T) {
+	ts := writeTaskFile(t, `
+env: {MODE: debug}
+tasks:
+  build: {cmd: echo building, env: {CGO_ENABLED: "0"}}
+  hooked: {before: [echo setup], cmd: [echo main], after: [echo teardown]}
+  quiet: {cmd: echo shh, silent: true}
+`)
+	var out bytes.Buffer
+	e := newExecutor(ts, RunOptions{})
+	e.stdout = &out
+	e.verbose = true
+	require.NoError(t, e.runTask(context.Background(), ts.Tasks["build"]))
+	assert.Equal(t, "==> build\n[build]   CGO_ENABLED=0\n[build]   MODE=debug\n[build] $ echo building\nbuilding\n",
+		stripColors(out.String()))
+
+	// Each command is echoed right before it starts, hooks included.
+	out.Reset()
+	require.NoError(t, e.runTask(context.Background(), ts.Tasks["hooked"]))
+	assert.Equal(t, "==> hooked\n[hooked]   MODE=debug\n"+
+		"[hooked] before $ echo setup\nsetup\n[hooked] $ echo main\nmain\n[hooked] after $ echo teardown\nteardown\n",
+		stripColors(out.String()))
+
+	out.Reset()
+	require.NoError(t, e.runTask(context.Background(), ts.Tasks["quiet"]))
+	assert.Equal(t, "shh\n", out.String())
+}
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, "-run", shellQuote("sh", "-run"))
+	assert.Equal(t, `'Test Foo'`, shellQuote("sh", "Test Foo"))
+	assert.Equal(t, `'it'\''s'`, shellQuote("/bin/bash", "it's"))
+	assert.Equal(t, `"say ""hi"""`, shellQuote("cmd.exe", `say "hi"`))
+	assert.Equal(t, `'it''s'`, shellQuote("pwsh", "it's"))
+}
+
+func TestExecutorTaskCwd(t *testing.T) {
+	ts := writeTaskFile(t, `
+env:
+  PKG: web
+tasks:
+  build: {cmd: touch built, cwd: packages/$PKG}
+  missing: {cmd: "true", cwd: nope}
+`)
+	require.NoError(t, os.MkdirAll(filepath.Join(ts.Dir(), "packages", "web"), 0o755))
+
+	e := newExecutor(ts, RunOptions{})
+	e.stdout = &bytes.Buffer{}
+	require.NoError(t, e.runTask(context.Background(), ts.Tasks["build"]))
+	assert.FileExists(t, filepath.Join(ts.Dir(), "packages", "web", "built"))
+
+	err := e.runTask(context.Background(), ts.Tasks["missing"])
+	assert.EqualError(t, err, `task "missing": working directory `+filepath.Join(ts.Dir(), "nope")+" does not exist")
+}
+
+func TestExecutorRunsCommandListInOrder(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  single: {cmd: echo one >> log}
+  steps:
+    cmd:
+      - echo first >> log
+      - exit 4
+      - echo never >> log
+  lenient:
+    cmd: [exit 1, echo after >> log]
+    ignore_errors: true
+`)
+	assert.Equal(t, CommandList{"echo one >> log"}, ts.Tasks["single"].Cmd)
+	read := func() string {
+		data, _ := os.ReadFile(filepath.Join(ts.Dir(), "log"))
+		return string(data)
+	}
+
+	var stderr bytes.Buffer
+	e := newExecutor(ts, RunOptions{})
+	e.stdout = &bytes.Buffer{}
+	e.stderr = &stderr
+	err := e.runTask(context.Background(), ts.Tasks["steps"])
+	assert.EqualError(t, err, "command 2/3 (exit 4): exit status 4")
+	assert.Equal(t, "first\n", read())
+
+	require.NoError(t, e.runTask(context.Background(), ts.Tasks["lenient"]))
+	assert.Equal(t, "first\nafter\n", read())
+	assert.Contains(t, stderr.String(), "lenient: command 1/2 (exit 1): exit status 1 (ignored)")
+}
+
+func TestRunConfirm(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  clean: {cmd: touch cleaned, confirm: "Delete everything?"}
+  build: {cmd: touch built}
+`)
+	var asked []string
+	answer := false
+	opts := RunOptions{Stdout: &bytes.Buffer{}, Confirm: func(t *Task) (bool, error) {
+		asked = append(asked, t.Confirm)
+		return answer, nil
+	}}
+
+	_, err := ts.Run(context.Background(), []string{"build"}, opts)
+	require.NoError(t, err)
+	assert.Empty(t, asked, "tasks without confirm run without asking")
+
+	_, err = ts.Run(context.Background(), []string{"clean"}, opts)
+	assert.ErrorIs(t, err, ErrNotConfirmed)
+	assert.Equal(t, []string{"Delete everything?"}, asked)
+	assert.NoFileExists(t, filepath.Join(ts.Dir(), "cleaned"))
+
+	answer = true
+	_, err = ts.Run(context.Background(), []string{"clean"}, opts)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(ts.Dir(), "cleaned"))
+
+	_, err = ts.Run(context.Background(), []string{"clean"}, RunOptions{Stdout: &bytes.Buffer{}})
+	assert.EqualError(t, err, `task "clean" failed: not confirmed`)
+}
+
+func TestRunBeforeAfterHooks(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  test:
+    before: [echo up >> log]
+    cmd: [echo test >> log, exit 2]
+    after: [echo down >> log]
+  flaky-teardown:
+    cmd: echo ok >> log
+    after: [exit 5, echo never >> log]
+  no-setup:
+    before: [exit 1]
+    cmd: echo skipped >> log
+    after: [echo cleanup >> log]
+`)
+	read := func() string {
+		data, _ := os.ReadFile(filepath.Join(ts.Dir(), "log"))
+		os.Remove(filepath.Join(ts.Dir(), "log"))
+		return string(data)
+	}
+	var stderr bytes.Buffer
+	e := newExecutor(ts, RunOptions{Stdout: &bytes.Buffer{}, Stderr: &stderr})
+
+	assert.EqualError(t, e.runTask(context.Background(), ts.Tasks["test"]), "command 2/2 (exit 2): exit status 2")
+	assert.Equal(t, "up\ntest\ndown\n", read())
+
+	require.NoError(t, e.runTask(context.Background(), ts.Tasks["flaky-teardown"]))
+	assert.Equal(t, "ok\n", read())
+	assert.Contains(t, stderr.String(), "flaky-teardown: after command 1/2 (exit 5): exit status 5 (warning)")
+
+	assert.EqualError(t, e.runTask(context.Background(), ts.Tasks["no-setup"]), "before command 1/1 (exit 1): exit status 1")
+	assert.Equal(t, "cleanup\n", read())
+}
+
+func TestRunLogDir(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  build:matrix: {cmd: "echo out; echo err >&2", before: [echo setup]}
+  lint: {cmd: echo lint}
+  noop: {deps: [build:matrix, lint]}
+`)
+	logDir := filepath.Join(ts.Dir(), "logs")
+	var out bytes.Buffer
+	res, err := ts.Run(context.Background(), []string{"noop"},
+		RunOptions{Stdout: &out, Stderr: &out, Jobs: 2, Prefix: true, LogDir: logDir})
+	require.NoError(t, err)
+	assert.Contains(t, stripColors(out.String()), "[build:matrix] out")
+
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(logDir, name))
+		require.NoError(t, err)
+		return string(data)
+	}
+	log := read("build_matrix.log")
+	assert.True(t, strings.HasPrefix(log, "setup\n"), log)
+	assert.ElementsMatch(t, []string{"setup", "out", "err"}, strings.Fields(log))
+	assert.Equal(t, "lint\n", read("lint.log"))
+
+	logs := map[string]string{}
+	for _, tr := range res.Tasks {
+		logs[tr.Task.Name] = tr.LogFile
+	}
+	assert.Equal(t, filepath.Join(logDir, "lint.log"), logs["lint"])
+	assert.Empty(t, logs["noop"], "tasks without commands have no log")
+	assert.NoFileExists(t, filepath.Join(logDir, "noop.log"))
+
+	_, err = ts.Run(context.Background(), []string{"lint"}, RunOptions{Stdout: &out, LogDir: logDir})
+	require.NoError(t, err)
+	assert.Equal(t, "lint\n", read("lint.log"), "each run truncates the log")
+}
+
+func TestRunLogDirDropsLogsOfSkippedTasks(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  build:
+    cmd: echo built > out
+    inputs: [zr.yaml]
+    outputs: [out]
+`)
+	logDir := filepath.Join(ts.Dir(), "logs")
+	opts := RunOptions{Stdout: &bytes.Buffer{}, LogDir: logDir}
+	_, err := ts.Run(context.Background(), []string{"build"}, opts)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(logDir, "build.log"))
+
+	res, err := ts.Run(context.Background(), []string{"build"}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "up to date", res.Tasks[0].SkipReason)
+	assert.Empty(t, res.Tasks[0].LogFile)
+	assert.NoFileExists(t, filepath.Join(logDir, "build.log"), "a skipped task keeps no log of an earlier run")
+}
@@ -0,0 +1,85 @@
+package zr
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/example/gocli/internal/glob"
+)
+
+// upToDate reports whether every output of t is newer than every input,
+// comparing modification times only. Tasks that don't declare both inputs
+// and outputs are never up to date, and neither is a task whose output
+// patterns match no existing file.
+func upToDate(root string, t *Task) (bool, error) {
+	if len(t.Inputs) == 0 || len(t.Outputs) == 0 {
+		return false, nil
+	}
+
+	outputs, ok, err := existingOutputs(root, t)
+	if err != nil || !ok {
+		return false, err
+	}
+	_, oldestOutput, err := mtimeBound(outputs, false)
+	if err != nil {
+		return false, err
+	}
+
+	inputs, err := glob.Expand(root, t.Inputs)
+	if err != nil {
+		return false, err
+	}
+	_, newestInput, err := mtimeBound(inputs, true)
+	if err != nil {
+		return false, err
+	}
+	return newestInput.Before(oldestOutput), nil
+}
+
+// existingOutputs expands the outputs of t and reports whether every
+// output pattern matched at least one file.
+func existingOutputs(root string, t *Task) ([]string, bool, error) {
+	outputs, err := glob.Expand(root, t.Outputs)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, pattern := range t.Outputs {
+		if strings.HasPrefix(pattern, "!") {
+			continue
+		}
+		if !anyMatch(root, glob.Compile(pattern), outputs) {
+			return nil, false, nil
+		}
+	}
+	return outputs, true, nil
+}
+
+func anyMatch(root string, g glob.Pattern, files []string) bool {
+	for _, f := range files {
+		rel, err := filepath.Rel(root, f)
+		if err == nil && g.Matches(filepath.ToSlash(rel)) {
+			return true
+		}
+	}
+	return false
+}
+
+// mtimeBound returns the newest (or oldest) of files and its modification
+// time.
+func mtimeBound(files []string, newest bool) (string, time.Time, error) {
+	var path string
+	var bound time.Time
+	for i, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		mt := info.ModTime()
+		if i == 0 || (newest && mt.After(bound)) || (!newest && mt.Before(bound)) {
+			path, bound = f, mt
+		}
+	}
+	return path, bound, nil
+}
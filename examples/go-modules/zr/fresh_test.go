@@ -0,0 +1,64 @@
+package zr
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func touch(t *testing.T, path string, mtime time.Time) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+}
+
+func TestUpToDate(t *testing.T) {
+	root := t.TempDir()
+	task := &Task{Name: "build", Inputs: []string{"*.go"}, Outputs: []string{"bin/app"}}
+	now := time.Now()
+
+	touch(t, filepath.Join(root, "main.go"), now.Add(-time.Hour))
+	fresh, err := upToDate(root, task)
+	require.NoError(t, err)
+	assert.False(t, fresh, "missing output must be stale")
+
+	touch(t, filepath.Join(root, "bin/app"), now)
+	fresh, err = upToDate(root, task)
+	require.NoError(t, err)
+	assert.True(t, fresh)
+
+	touch(t, filepath.Join(root, "main.go"), now.Add(time.Hour))
+	fresh, err = upToDate(root, task)
+	require.NoError(t, err)
+	assert.False(t, fresh, "input newer than output must be stale")
+}
+
+func TestUpToDateRequiresInputsAndOutputs(t *testing.T) {
+	fresh, err := upToDate(t.TempDir(), &Task{Name: "test", Inputs: []string{"*.go"}})
+	require.NoError(t, err)
+	assert.False(t, fresh)
+}
+
+func TestExecutorSkipsUpToDateTask(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  build: {cmd: echo ran >> log, inputs: [src], outputs: [out]}
+`)
+	now := time.Now()
+	touch(t, filepath.Join(ts.Dir(), "src"), now.Add(-time.Hour))
+	touch(t, filepath.Join(ts.Dir(), "out"), now)
+
+	e := newExecutor(ts, RunOptions{})
+	var out bytes.Buffer
+	e.stdout = &out
+	require.NoError(t, e.runTask(context.Background(), ts.Tasks["build"]))
+	assert.Equal(t, "build: up to date (skipped)\n", out.String())
+	assert.NoFileExists(t, filepath.Join(ts.Dir(), "log"))
+}
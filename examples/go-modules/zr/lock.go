@@ -0,0 +1,68 @@
+package zr
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lockPoll is how often a task waiting for a lock tries it again.
+var lockPoll = 100 * time.Millisecond
+
+// fileLock is an exclusive lock on a file, held through an open handle so
+// the operating system releases it if zr dies.
+type fileLock struct {
+	f *os.File
+}
+
+// lockPath returns the file backing the lock called name. Locks live in
+// the cache directory, so every zr process using the same task file
+// shares them.
+func (ts *TaskSet) lockPath(name string) string {
+	return filepath.Join(ts.cacheDir(), "locks", url.PathEscape(name)+".lock")
+}
+
+// acquireLock takes the lock on the file at path, creating it if needed.
+// If another task or process holds it, waiting is called once and the
+// lock is retried until it is free or ctx is done.
+func acquireLock(ctx context.Context, path string, waiting func()) (*fileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	announced := false
+	for {
+		ok, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("lock %s: %w", path, err)
+		}
+		if ok {
+			return &fileLock{f: f}, nil
+		}
+		if !announced {
+			waiting()
+			announced = true
+		}
+		select {
+		case <-time.After(lockPoll):
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (l *fileLock) release() error {
+	err := unlock(l.f)
+	if closeErr := l.f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,50 @@
+package zr
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "locks", "db.lock")
+	held, err := acquireLock(context.Background(), path, func() { t.Fatal("free lock should not wait") })
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*lockPoll)
+	defer cancel()
+	waited := 0
+	_, err = acquireLock(ctx, path, func() { waited++ })
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, waited)
+
+	require.NoError(t, held.release())
+	again, err := acquireLock(context.Background(), path, func() { t.Fatal("released lock should not wait") })
+	require.NoError(t, err)
+	require.NoError(t, again.release())
+}
+
+func TestRunSerializesTasksSharingALock(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  a: {cmd: "echo start >> log && sleep 0.3 && echo end >> log", lock: db}
+  b: {cmd: "echo start >> log && sleep 0.3 && echo end >> log", lock: db}
+`)
+	var out bytes.Buffer
+	start := time.Now()
+	_, err := ts.Run(context.Background(), []string{"a", "b"}, RunOptions{Stdout: &out, Jobs: 2})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 600*time.Millisecond)
+
+	data, err := os.ReadFile(filepath.Join(ts.Dir(), "log"))
+	require.NoError(t, err)
+	assert.Equal(t, "start\nend\nstart\nend\n", string(data))
+	assert.Regexp(t, `[ab]: waiting for lock "db"\.\.\.`, out.String())
+	assert.FileExists(t, filepath.Join(ts.Dir(), ".zr", "cache", "locks", "db.lock"))
+}
@@ -0,0 +1,18 @@
+package zr
+
+import (
+	"io"
+	"log/slog"
+)
+
+// logger receives zr's own diagnostics: how task files are loaded, how
+// plans are resolved, cache decisions and the processes tasks spawn. It is
+// separate from task output, and discards everything until SetLogger is
+// called.
+var logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+
+// SetLogger makes l receive zr's diagnostics, which are logged at debug
+// level.
+func SetLogger(l *slog.Logger) {
+	logger = l
+}
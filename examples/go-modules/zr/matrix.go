@@ -0,0 +1,116 @@
+package zr
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Matrix is the matrix of a task: named axes, in the order the task file
+// lists them, each with the values the task is expanded over.
+type Matrix []MatrixAxis
+
+// MatrixAxis is one named dimension of a Matrix.
+type MatrixAxis struct {
+	Name   string
+	Values []string
+}
+
+func (m *Matrix) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: matrix must be a map of names to lists of values", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		axis := MatrixAxis{Name: node.Content[i].Value}
+		if err := node.Content[i+1].Decode(&axis.Values); err != nil {
+			return err
+		}
+		*m = append(*m, axis)
+	}
+	return nil
+}
+
+// combinations returns every combination of one value per axis, with the
+// first axis varying slowest.
+func (m Matrix) combinations() []map[string]string {
+	combos := []map[string]string{{}}
+	for _, axis := range m {
+		var next []map[string]string
+		for _, c := range combos {
+			for _, v := range axis.Values {
+				combo := maps.Clone(c)
+				combo[axis.Name] = v
+				next = append(next, combo)
+			}
+		}
+		combos = next
+	}
+	return combos
+}
+
+// name returns the part of an expanded task's name after the colon: the
+// combination's values in axis order, joined by dashes, with slashes also
+// turned into dashes, e.g. linux-amd64.
+func (m Matrix) name(combo map[string]string) string {
+	parts := make([]string, len(m))
+	for i, axis := range m {
+		parts[i] = strings.ReplaceAll(combo[axis.Name], "/", "-")
+	}
+	return strings.Join(parts, "-")
+}
+
+// expandMatrices replaces every task with a matrix by one task per
+// combination of its matrix values, named task:values (e.g.
+// build:linux-amd64), whose templates can use {{ .matrix.name }}. The
+// original task keeps its name, description, group and aliases but only
+// depends on its expansions, so running it runs them all.
+func (ts *TaskSet) expandMatrices() error {
+	for _, name := range ts.Names() {
+		t := ts.Tasks[name]
+		if len(t.Matrix) == 0 {
+			continue
+		}
+		for _, axis := range t.Matrix {
+			if len(axis.Values) == 0 {
+				return fmt.Errorf("task %q: matrix %q has no values", name, axis.Name)
+			}
+		}
+		var expanded []string
+		for _, combo := range t.Matrix.combinations() {
+			x := *t
+			x.Name = name + ":" + t.Matrix.name(combo)
+			if _, ok := ts.Tasks[x.Name]; ok || slices.Contains(expanded, x.Name) {
+				return fmt.Errorf("task %q: matrix expansion %q is already a task", name, x.Name)
+			}
+			x.Aliases = nil
+			x.Matrix = nil
+			x.matrixVars = combo
+			// Templates are rendered in place, so every expansion needs
+			// its own copies.
+			x.Cmd = slices.Clone(t.Cmd)
+			x.Before = slices.Clone(t.Before)
+			x.After = slices.Clone(t.After)
+			x.Deps = slices.Clone(t.Deps)
+			x.RunAfter = slices.Clone(t.RunAfter)
+			x.Inputs = slices.Clone(t.Inputs)
+			x.Outputs = slices.Clone(t.Outputs)
+			x.Env = maps.Clone(t.Env)
+			ts.Tasks[x.Name] = &x
+			expanded = append(expanded, x.Name)
+		}
+		logger.Debug("expanded matrix", "task", name, "tasks", expanded)
+		ts.Tasks[name] = &Task{
+			Name:        name,
+			Description: t.Description,
+			Group:       t.Group,
+			Aliases:     t.Aliases,
+			OS:          t.OS,
+			Deps:        expanded,
+			source:      t.source,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package zr
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandMatrices(t *testing.T) {
+	ts := writeTaskFile(t, `
+vars: {out: bin}
+tasks:
+  gen: {cmd: go generate}
+  build:
+    description: Cross-compile
+    aliases: [b]
+    deps: [gen]
+    matrix:
+      os: [linux, darwin]
+      arch: [amd64, arm64]
+    cmd: go build -o {{ .vars.out }}/{{ .matrix.os }}-{{ .matrix.arch }}
+    outputs: ["{{ .vars.out }}/{{ .matrix.os }}-{{ .matrix.arch }}"]
+    env: {GOOS: "{{ .matrix.os }}", GOARCH: "{{ .matrix.arch }}"}
+  release: {cmd: ./release, deps: [b]}
+`)
+	assert.Equal(t, []string{
+		"build", "build:darwin-amd64", "build:darwin-arm64", "build:linux-amd64", "build:linux-arm64",
+		"gen", "release",
+	}, ts.Names())
+
+	x := ts.Tasks["build:linux-arm64"]
+	assert.Equal(t, CommandList{"go build -o bin/linux-arm64"}, x.Cmd)
+	assert.Equal(t, []string{"bin/linux-arm64"}, x.Outputs)
+	assert.Equal(t, map[string]string{"GOOS": "linux", "GOARCH": "arm64"}, x.Env)
+	assert.Equal(t, []string{"gen"}, x.Deps)
+	assert.Equal(t, "Cross-compile", x.Description)
+
+	build := ts.Tasks["build"]
+	assert.Empty(t, build.Cmd)
+	assert.Equal(t, []string{"build:linux-amd64", "build:linux-arm64", "build:darwin-amd64", "build:darwin-arm64"}, build.Deps)
+	assert.Equal(t, "build", ts.Resolve("b"))
+
+	plan, err := ts.Plan([]string{"release"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"gen"},
+		{"build:darwin-amd64", "build:darwin-arm64", "build:linux-amd64", "build:linux-arm64"},
+		{"build"},
+		{"release"},
+	}, levelNames(plan))
+}
+
+func TestExpandMatrixErrors(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"empty.yaml":    "tasks:\n  build: {cmd: go build, matrix: {os: []}}\n",
+		"clash.yaml":    "tasks:\n  build: {matrix: {target: [linux/amd64, linux-amd64]}}\n",
+		"list.yaml":     "tasks:\n  build: {matrix: [linux, darwin]}\n",
+		"nomatrix.yaml": "tasks:\n  build: {cmd: \"echo {{ .matrix.os }}\"}\n",
+	})
+	_, err := LoadTasks(filepath.Join(root, "empty.yaml"))
+	assert.EqualError(t, err, `task "build": matrix "os" has no values`)
+	_, err = LoadTasks(filepath.Join(root, "clash.yaml"))
+	assert.EqualError(t, err, `task "build": matrix expansion "build:linux-amd64" is already a task`)
+	_, err = LoadTasks(filepath.Join(root, "list.yaml"))
+	assert.ErrorContains(t, err, "matrix must be a map of names to lists of values")
+	_, err = LoadTasks(filepath.Join(root, "nomatrix.yaml"))
+	assert.ErrorContains(t, err, `map has no entry for key "os"`)
+}
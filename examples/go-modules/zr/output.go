@@ -0,0 +1,89 @@
+package zr
+
+import (
+	"bytes"
+	"hash/fnv"
+	"io"
+	"sync"
+)
+
+// zr's own output uses ANSI colors; the command line strips them when the
+// terminal can't render them.
+const (
+	colorReset   = "\x1b[0m"
+	colorDim     = "\x1b[2m"
+	colorRed     = "\x1b[31m"
+	colorGreen   = "\x1b[32m"
+	colorYellow  = "\x1b[33m"
+	colorBlue    = "\x1b[34m"
+	colorMagenta = "\x1b[35m"
+	colorCyan    = "\x1b[36m"
+)
+
+func colorize(color, s string) string {
+	return color + s + colorReset
+}
+
+// taskColors are cycled through to tell tasks apart in prefixed output.
+var taskColors = []string{colorCyan, colorGreen, colorYellow, colorBlue, colorMagenta}
+
+// taskColor picks a stable color for the task called name.
+func taskColor(name string) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return taskColors[h.Sum32()%uint32(len(taskColors))]
+}
+
+// syncWriter serializes the writes of the tasks running at the same time,
+// which share the executor's writers. Writers sharing a destination share
+// mu.
+type syncWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// linePrefixer writes each complete line to w with prefix in front. A
+// trailing partial line is held back until a later Write completes it or
+// Flush is called at EOF. Each line is a single Write, so prefixers on a
+// syncWriter never interleave their lines.
+type linePrefixer struct {
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (p *linePrefixer) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := p.emit(p.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+// Flush writes out a pending partial line, terminating it with a newline.
+func (p *linePrefixer) Flush() error {
+	if len(p.buf) == 0 {
+		return nil
+	}
+	err := p.emit(append(p.buf, '\n'))
+	p.buf = nil
+	return err
+}
+
+func (p *linePrefixer) emit(line []byte) error {
+	_, err := p.w.Write(append([]byte(p.prefix), line...))
+	return err
+}
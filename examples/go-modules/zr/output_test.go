@@ -0,0 +1,43 @@
+package zr
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var sgrPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// stripColors removes ANSI color sequences from s.
+func stripColors(s string) string {
+	return sgrPattern.ReplaceAllString(s, "")
+}
+
+func TestLinePrefixer(t *testing.T) {
+	var buf bytes.Buffer
+	p := &linePrefixer{w: &buf, prefix: "[build] "}
+	p.Write([]byte("compil"))
+	assert.Empty(t, buf.String(), "partial lines are held back")
+	p.Write([]byte("ing...\nlinking\nno newline"))
+	assert.Equal(t, "[build] compiling...\n[build] linking\n", buf.String())
+	require.NoError(t, p.Flush())
+	assert.Equal(t, "[build] compiling...\n[build] linking\n[build] no newline\n", buf.String())
+}
+
+func TestExecutorPrefixesStreams(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  build: {cmd: "echo out; printf err >&2"}
+`)
+	var stdout, stderr bytes.Buffer
+	e := newExecutor(ts, RunOptions{})
+	e.stdout, e.stderr = &stdout, &stderr
+	e.prefix = true
+	require.NoError(t, e.runTask(context.Background(), ts.Tasks["build"]))
+	assert.Contains(t, stripColors(stdout.String()), "[build] out\n")
+	assert.Equal(t, "[build] err\n", stripColors(stderr.String()))
+}
@@ -0,0 +1,39 @@
+//go:build !windows
+
+package zr
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// setProcessGroup makes c the leader of a new process group so it and
+// everything it spawns can be signalled together.
+func setProcessGroup(c *exec.Cmd) {
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func terminateGroup(c *exec.Cmd) {
+	syscall.Kill(-c.Process.Pid, syscall.SIGTERM)
+}
+
+func killGroup(c *exec.Cmd) {
+	syscall.Kill(-c.Process.Pid, syscall.SIGKILL)
+}
+
+// niceSupported reports whether setNice works on this platform.
+const niceSupported = true
+
+// setNice makes c run through nice(1), so the shell already starts at the
+// adjusted niceness and everything it spawns inherits it. It must be
+// called before c is started.
+func setNice(c *exec.Cmd, nice int) error {
+	path, err := exec.LookPath("nice")
+	if err != nil {
+		return err
+	}
+	c.Args = append([]string{"nice", "-n", strconv.Itoa(nice), c.Path}, c.Args[1:]...)
+	c.Path = path
+	return nil
+}
@@ -0,0 +1,114 @@
+//go:build !windows
+
+package zr
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunTaskTimeoutKillsProcessTree(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  hang:
+    cmd: sleep 30 & echo $! > child.pid; wait
+    timeout: 200ms
+`)
+	killGrace = 100 * time.Millisecond
+	t.Cleanup(func() { killGrace = 5 * time.Second })
+
+	e := newExecutor(ts, RunOptions{})
+	e.stdout = &bytes.Buffer{}
+	start := time.Now()
+	err := e.runTask(context.Background(), ts.Tasks["hang"])
+	assert.EqualError(t, err, "timed out after 200ms")
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	data, err := os.ReadFile(filepath.Join(ts.Dir(), "child.pid"))
+	require.NoError(t, err)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return syscall.Kill(pid, 0) != nil
+	}, 10*time.Second, 20*time.Millisecond, "background child should be gone")
+}
+
+func TestRunTaskTimeoutCoversEveryCommand(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  slow:
+    before: [sleep 0.15]
+    cmd: [sleep 0.15, sleep 0.15, touch done]
+    after: [touch cleaned]
+    timeout: 400ms
+`)
+	e := newExecutor(ts, RunOptions{})
+	e.stdout = &bytes.Buffer{}
+	start := time.Now()
+	err := e.runTask(context.Background(), ts.Tasks["slow"])
+	assert.EqualError(t, err, "command 2/3 (sleep 0.15): timed out after 400ms")
+	assert.Less(t, time.Since(start), 450*time.Millisecond+killGrace)
+	assert.NoFileExists(t, filepath.Join(ts.Dir(), "done"))
+	assert.FileExists(t, filepath.Join(ts.Dir(), "cleaned"), "after commands still run once the task timed out")
+}
+
+func TestRunTaskRetries(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  flaky:
+    cmd: echo try >> attempts; test $(wc -l < attempts) -ge 3
+    retries: 3
+    retry_delay: 10ms
+  hang:
+    cmd: echo try >> hangs; sleep 5
+    timeout: 50ms
+    retries: 2
+`)
+	killGrace = 100 * time.Millisecond
+	t.Cleanup(func() { killGrace = 5 * time.Second })
+
+	var out bytes.Buffer
+	e := newExecutor(ts, RunOptions{})
+	e.stdout = &out
+	require.NoError(t, e.runTask(context.Background(), ts.Tasks["flaky"]))
+	assert.Contains(t, out.String(), "flaky: retry 1/3\nflaky: retry 2/3\n")
+	assert.NotContains(t, out.String(), "retry 3/3")
+
+	// Timeouts aren't retried unless retry_on_timeout is set.
+	assert.EqualError(t, e.runTask(context.Background(), ts.Tasks["hang"]), "timed out after 50ms")
+	data, err := os.ReadFile(filepath.Join(ts.Dir(), "hangs"))
+	require.NoError(t, err)
+	assert.Equal(t, "try\n", string(data))
+
+	ts.Tasks["hang"].RetryOnTimeout = true
+	os.Remove(filepath.Join(ts.Dir(), "hangs"))
+	assert.Error(t, e.runTask(context.Background(), ts.Tasks["hang"]))
+	data, err = os.ReadFile(filepath.Join(ts.Dir(), "hangs"))
+	require.NoError(t, err)
+	assert.Equal(t, "try\ntry\ntry\n", string(data))
+}
+
+func TestRunTaskNice(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  low:
+    cmd: ps -o ni= -p $$ > nice; sh -c 'ps -o ni= -p $$' >> nice
+    nice: 10
+`)
+	e := newExecutor(ts, RunOptions{})
+	e.stdout = &bytes.Buffer{}
+	require.NoError(t, e.runTask(context.Background(), ts.Tasks["low"]))
+	data, err := os.ReadFile(filepath.Join(ts.Dir(), "nice"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10", "10"}, strings.Fields(string(data)), "the shell and what it spawns")
+}
@@ -0,0 +1,33 @@
+//go:build windows
+
+package zr
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// setProcessGroup starts c in a new process group so console signals aimed
+// at zr don't reach it directly.
+func setProcessGroup(c *exec.Cmd) {
+	c.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// terminateGroup asks c and its descendants to close.
+func terminateGroup(c *exec.Cmd) {
+	exec.Command("taskkill", "/T", "/PID", strconv.Itoa(c.Process.Pid)).Run()
+}
+
+// killGroup forcibly ends c and its descendants.
+func killGroup(c *exec.Cmd) {
+	exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(c.Process.Pid)).Run()
+}
+
+// niceSupported reports whether setNice works on this platform. Windows
+// priority classes don't map onto niceness, so nice is ignored.
+const niceSupported = false
+
+func setNice(c *exec.Cmd, nice int) error {
+	return nil
+}
@@ -0,0 +1,230 @@
+package zr
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/example/gocli/internal/glob"
+)
+
+// RemoteCache is a cache shared between machines, such as ephemeral CI
+// runners. It stores an archive of the outputs of a successful run under
+// the run's cache key, so another machine computing the same key can
+// restore the outputs instead of running the task.
+type RemoteCache interface {
+	// Fetch writes the archive stored under key to w and reports whether
+	// there was one.
+	Fetch(ctx context.Context, key string, w io.Writer) (bool, error)
+	// Store stores the archive read from r under key.
+	Store(ctx context.Context, key string, r io.Reader) error
+}
+
+// HTTPCache is a RemoteCache on an HTTP server that keeps the archive of
+// each key at URL/<key>.tar.gz. Fetch GETs it, a 404 meaning there is none,
+// and Store PUTs it, which is all that most build cache servers, WebDAV
+// shares and S3-compatible gateways need.
+type HTTPCache struct {
+	URL string
+	// Token, if set, is sent as a bearer token with every request.
+	Token string
+	// Client makes the requests; nil means http.DefaultClient.
+	Client *http.Client
+}
+
+func (c *HTTPCache) Fetch(ctx context.Context, key string, w io.Writer) (bool, error) {
+	resp, err := c.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("GET %s: %s", resp.Request.URL.Redacted(), resp.Status)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err == nil, err
+}
+
+func (c *HTTPCache) Store(ctx context.Context, key string, r io.Reader) error {
+	resp, err := c.do(ctx, http.MethodPut, key, r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("PUT %s: %s", resp.Request.URL.Redacted(), resp.Status)
+	}
+	return nil
+}
+
+func (c *HTTPCache) do(ctx context.Context, method, key string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(c.URL, "/")+"/"+key+".tar.gz", body)
+	if err != nil {
+		return nil, err
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// restoreRemote fetches the archive of key from the remote cache and
+// unpacks the outputs of t in it, reporting whether there was one and all
+// of the outputs are now present.
+func (e *executor) restoreRemote(ctx context.Context, t *Task, key string) (bool, error) {
+	var archive bytes.Buffer
+	found, err := e.remote.Fetch(ctx, key, &archive)
+	if err != nil || !found {
+		return false, err
+	}
+	if err := unpackOutputs(e.ts.Dir(), t, &archive); err != nil {
+		return false, err
+	}
+	_, ok, err := existingOutputs(e.ts.Dir(), t)
+	return ok, err
+}
+
+// storeRemote archives the outputs of t and stores them in the remote
+// cache under key. Nothing is stored when some of the outputs are missing,
+// as restoring them could never make the task cached.
+func (e *executor) storeRemote(ctx context.Context, t *Task, key string) error {
+	outputs, ok, err := existingOutputs(e.ts.Dir(), t)
+	if err != nil || !ok {
+		return err
+	}
+	var archive bytes.Buffer
+	if err := packOutputs(e.ts.Dir(), outputs, &archive); err != nil {
+		return err
+	}
+	return e.remote.Store(ctx, key, &archive)
+}
+
+// packOutputs writes files, which are inside root, to w as a gzipped tar
+// archive with paths relative to root.
+func packOutputs(root string, files []string, w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, path := range files {
+		if err := addFile(tw, root, path); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func addFile(tw *tar.Writer, root, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return err
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Name:     filepath.ToSlash(rel),
+		Typeflag: tar.TypeReg,
+		Mode:     int64(info.Mode().Perm()),
+		Size:     info.Size(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// unpackOutputs extracts an archive written by packOutputs for t into
+// root, replacing files that already exist. Entries that aren't regular
+// files, whose path would leave root or that aren't outputs of t are
+// rejected. Files are staged first and only moved into place once the
+// whole archive has been read, so a rejected or truncated archive changes
+// nothing.
+func unpackOutputs(root string, t *Task, r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	// Staging next to the cache keeps the files on the same file system,
+	// so moving them into place is a rename.
+	if err := os.MkdirAll(filepath.Join(root, ".zr"), 0o755); err != nil {
+		return err
+	}
+	staging, err := os.MkdirTemp(filepath.Join(root, ".zr"), "restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	outputs := glob.CompileSet(t.Outputs)
+	var names []string
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.FromSlash(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || !filepath.IsLocal(name) {
+			return fmt.Errorf("archive entry %q is not a file inside the task directory", hdr.Name)
+		}
+		if !outputs.Matches(filepath.ToSlash(name)) {
+			return fmt.Errorf("archive entry %q is not an output of %s", hdr.Name, t.Name)
+		}
+		if err := extractFile(tr, filepath.Join(staging, name), os.FileMode(hdr.Mode).Perm()); err != nil {
+			return err
+		}
+		names = append(names, name)
+	}
+	for _, name := range names {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.Rename(filepath.Join(staging, name), path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractFile(r io.Reader, path string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,162 @@
+package zr
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryServer is an HTTP cache server keeping archives in memory. It
+// only answers requests carrying token.
+func memoryServer(t *testing.T, token string) (*httptest.Server, map[string][]byte) {
+	t.Helper()
+	var mu sync.Mutex
+	blobs := map[string][]byte{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodGet:
+			blob, ok := blobs[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(blob)
+		case http.MethodPut:
+			blob, _ := io.ReadAll(r.Body)
+			blobs[r.URL.Path] = blob
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, blobs
+}
+
+func TestRemoteCacheRestoresOutputs(t *testing.T) {
+	const taskFile = `
+tasks:
+  build:
+    cmd: echo run >> runs; mkdir -p bin; cp main.go bin/app
+    inputs: [main.go]
+    outputs: [bin/app]
+`
+	srv, blobs := memoryServer(t, "secret")
+	remote := &HTTPCache{URL: srv.URL + "/zr/", Token: "secret"}
+
+	first := writeTaskFile(t, taskFile)
+	require.NoError(t, os.WriteFile(filepath.Join(first.Dir(), "main.go"), []byte("package main\n"), 0o644))
+	_, err := first.Run(context.Background(), []string{"build"}, RunOptions{Stdout: &bytes.Buffer{}, Remote: remote})
+	require.NoError(t, err)
+	assert.Len(t, blobs, 1)
+
+	// A fresh checkout elsewhere has the same inputs and nothing else.
+	second := writeTaskFile(t, taskFile)
+	require.NoError(t, os.WriteFile(filepath.Join(second.Dir(), "main.go"), []byte("package main\n"), 0o644))
+	var out bytes.Buffer
+	res, err := second.Run(context.Background(), []string{"build"}, RunOptions{Stdout: &out, Remote: remote})
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "build: restored from remote cache (skipped)")
+	assert.Equal(t, "cached", res.Tasks[0].SkipReason)
+	assert.NoFileExists(t, filepath.Join(second.Dir(), "runs"))
+	data, err := os.ReadFile(filepath.Join(second.Dir(), "bin", "app"))
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n", string(data))
+
+	// A remote that rejects us only warns, and the task runs.
+	third := writeTaskFile(t, taskFile)
+	require.NoError(t, os.WriteFile(filepath.Join(third.Dir(), "main.go"), []byte("package main\n"), 0o644))
+	var stderr bytes.Buffer
+	_, err = third.Run(context.Background(), []string{"build"}, RunOptions{
+		Stdout: &bytes.Buffer{}, Stderr: &stderr, Remote: &HTTPCache{URL: srv.URL},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, stderr.String(), "build: remote cache: GET "+srv.URL+"/")
+	assert.Contains(t, stderr.String(), "401 Unauthorized (warning)")
+	assert.FileExists(t, filepath.Join(third.Dir(), "runs"))
+}
+
+func TestUnpackOutputsRejectsEscapingPaths(t *testing.T) {
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "../evil", Typeflag: tar.TypeReg, Mode: 0o644, Size: 1}))
+	tw.Write([]byte("x"))
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	root := t.TempDir()
+	err := unpackOutputs(root, &Task{Name: "build", Outputs: []string{"**"}}, &archive)
+	assert.EqualError(t, err, `archive entry "../evil" is not a file inside the task directory`)
+	assert.NoFileExists(t, filepath.Join(filepath.Dir(root), "evil"))
+}
+
+func TestRemoteCacheNeedsEveryOutput(t *testing.T) {
+	const taskFile = `
+tasks:
+  build:
+    cmd: echo run >> runs
+    inputs: [main.go]
+    outputs: [out.bin]
+`
+	srv, blobs := memoryServer(t, "secret")
+	remote := &HTTPCache{URL: srv.URL, Token: "secret"}
+
+	// A run that didn't produce its outputs isn't stored.
+	first := writeTaskFile(t, taskFile)
+	require.NoError(t, os.WriteFile(filepath.Join(first.Dir(), "main.go"), []byte("package main\n"), 0o644))
+	_, err := first.Run(context.Background(), []string{"build"}, RunOptions{Stdout: &bytes.Buffer{}, Remote: remote})
+	require.NoError(t, err)
+	assert.Empty(t, blobs)
+
+	// Nor does an archive missing some of them count as a hit.
+	key, err := first.cacheKey(first.Tasks["build"])
+	require.NoError(t, err)
+	var archive bytes.Buffer
+	require.NoError(t, packOutputs(first.Dir(), nil, &archive))
+	blobs["/"+key+".tar.gz"] = archive.Bytes()
+
+	second := writeTaskFile(t, taskFile)
+	require.NoError(t, os.WriteFile(filepath.Join(second.Dir(), "main.go"), []byte("package main\n"), 0o644))
+	var out bytes.Buffer
+	_, err = second.Run(context.Background(), []string{"build"}, RunOptions{Stdout: &out, Remote: remote})
+	require.NoError(t, err)
+	assert.NotContains(t, out.String(), "restored from remote cache")
+	assert.FileExists(t, filepath.Join(second.Dir(), "runs"))
+}
+
+func TestUnpackOutputsRejectsUndeclaredFiles(t *testing.T) {
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	for _, name := range []string{"bin/app", "zr.yaml"} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: 1}))
+		tw.Write([]byte("x"))
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "zr.yaml"), []byte("tasks: {}\n"), 0o644))
+	err := unpackOutputs(root, &Task{Name: "build", Outputs: []string{"bin/"}}, &archive)
+	assert.EqualError(t, err, `archive entry "zr.yaml" is not an output of build`)
+	assert.NoFileExists(t, filepath.Join(root, "bin", "app"), "nothing is restored from a rejected archive")
+	data, err := os.ReadFile(filepath.Join(root, "zr.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "tasks: {}\n", string(data))
+}
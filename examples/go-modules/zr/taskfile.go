@@ -0,0 +1,493 @@
+// Package zr loads task files, resolves the tasks to run and their
+// dependencies into a plan, and executes it. It is the engine behind the zr
+// command line and can be embedded in other Go programs:
+//
+//	ts, err := zr.LoadTasks("zr.yaml")
+//	if err != nil {
+//		return err
+//	}
+//	res, err := ts.Run(ctx, []string{"test"}, zr.RunOptions{})
+package zr
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFileName is the task file name FindTaskFile looks for.
+const DefaultFileName = "zr.yaml"
+
+// Task is a single named unit of work from the task file.
+type Task struct {
+	Name        string            `yaml:"-"`
+	Description string            `yaml:"description"`
+	Group       string            `yaml:"group"`
+	Aliases     []string          `yaml:"aliases"`
+	Cmd         CommandList       `yaml:"cmd"`
+	Deps        []string          `yaml:"deps"`
+	Inputs      []string          `yaml:"inputs"`
+	Outputs     []string          `yaml:"outputs"`
+	Watch       []string          `yaml:"watch"`
+	Env         map[string]string `yaml:"env"`
+	EnvFile     EnvFileList       `yaml:"env_file"`
+	Shell       string            `yaml:"shell"`
+	Silent      bool              `yaml:"silent"`
+
+	// Cwd is the directory the command runs in, relative to the task file.
+	// Inputs and outputs stay relative to the task file either way.
+	Cwd string `yaml:"cwd"`
+
+	// Before runs ahead of Cmd and After once it has finished, whether or
+	// not it succeeded, e.g. to start and stop a service the commands need.
+	// A failing Before command fails the task; a failing After command is
+	// only a warning.
+	Before CommandList `yaml:"before"`
+	After  CommandList `yaml:"after"`
+
+	// RunAfter only orders the task: when a task it names is part of the
+	// same run, this task starts once that one has finished, whether or
+	// not it succeeded. Unlike Deps, it never adds tasks to a run. (After
+	// is taken by the commands run after Cmd.)
+	RunAfter []string `yaml:"run_after"`
+
+	// Matrix expands the task into one task per combination of its values;
+	// see expandMatrices.
+	Matrix Matrix `yaml:"matrix"`
+
+	// OS lists the operating systems, as GOOS values like linux or
+	// windows, the task is for. Elsewhere it is skipped instead of run; an
+	// empty list means every system.
+	OS []string `yaml:"os"`
+
+	// Nice is the niceness adjustment the task's processes run with, from
+	// -20 (highest priority) to 19 (lowest), where supported; zero leaves
+	// it alone. Like nice(1), it is added to zr's own niceness and already
+	// applies to the shell. Raising priority usually needs privileges.
+	Nice int `yaml:"nice"`
+
+	// Lock names a lock the task holds while it runs. Tasks sharing a lock
+	// never run at the same time, even in different zr processes.
+	Lock string `yaml:"lock"`
+
+	// Confirm is a question the user must answer yes to before the task
+	// runs, for tasks that are hard to undo.
+	Confirm string `yaml:"confirm"`
+
+	// IgnoreErrors keeps going when a command fails, and lets the task
+	// succeed regardless.
+	IgnoreErrors bool `yaml:"ignore_errors"`
+
+	// Timeout, if non-zero, bounds the before commands and commands of the
+	// task together; once it elapses they are stopped and the task fails.
+	// The after commands get the same timeout of their own, so they can
+	// still clean up, and retrying a timed-out command starts it over.
+	Timeout time.Duration `yaml:"timeout"`
+
+	// Retries is how many more times a failing command is run before the
+	// task fails.
+	Retries        int           `yaml:"retries"`
+	RetryDelay     time.Duration `yaml:"retry_delay"`
+	RetryOnTimeout bool          `yaml:"retry_on_timeout"`
+
+	// source is the file the task was defined in.
+	source string
+	// fileEnv holds the variables read from EnvFile.
+	fileEnv map[string]string
+	// matrixVars holds the matrix values of a task expanded from a matrix.
+	matrixVars map[string]string
+}
+
+// CommandList is the commands of a task, run one after another. In the
+// task file it is either a single string or a list of strings.
+type CommandList []string
+
+func (c *CommandList) UnmarshalYAML(node *yaml.Node) error {
+	cmds, err := decodeStringOrList(node)
+	*c = cmds
+	return err
+}
+
+// EnvFileList is the env files of a task or task file, given as a single
+// path or a list of paths.
+type EnvFileList []string
+
+func (f *EnvFileList) UnmarshalYAML(node *yaml.Node) error {
+	files, err := decodeStringOrList(node)
+	*f = files
+	return err
+}
+
+// decodeStringOrList decodes a string, treating an empty one as no
+// strings, or a list of strings.
+func decodeStringOrList(node *yaml.Node) ([]string, error) {
+	if node.Kind == yaml.ScalarNode {
+		var s string
+		if err := node.Decode(&s); err != nil || s == "" {
+			return nil, err
+		}
+		return []string{s}, nil
+	}
+	var list []string
+	err := node.Decode(&list)
+	return list, err
+}
+
+// Lines returns every line of every command, for display.
+func (c CommandList) Lines() []string {
+	var lines []string
+	for _, cmd := range c {
+		lines = append(lines, strings.Split(strings.TrimRight(cmd, "\n"), "\n")...)
+	}
+	return lines
+}
+
+// Cacheable reports whether the task declares the inputs and outputs needed
+// to skip it when nothing changed.
+func (t *Task) Cacheable() bool {
+	return len(t.Inputs) > 0 && len(t.Outputs) > 0
+}
+
+// RunsOn reports whether the task is for the operating system goos.
+func (t *Task) RunsOn(goos string) bool {
+	return len(t.OS) == 0 || slices.Contains(t.OS, goos)
+}
+
+// TaskSet is the parsed contents of a task file and everything it includes.
+// Tasks are keyed by name; look them up with Lookup to also resolve
+// aliases.
+type TaskSet struct {
+	Path    string            `yaml:"-"`
+	Include []string          `yaml:"include"`
+	Env     map[string]string `yaml:"env"`
+	EnvFile EnvFileList       `yaml:"env_file"`
+	Shell   string            `yaml:"shell"`
+	Default string            `yaml:"default"`
+	Tasks   map[string]*Task  `yaml:"tasks"`
+
+	// Vars are substituted for {{ .vars.name }} in commands, cwd and env
+	// values. Only the root file's vars are used, and they apply to the
+	// tasks of included files too.
+	Vars map[string]string `yaml:"vars"`
+
+	// files lists every file that contributed tasks, in load order.
+	files []string
+	// aliases maps each alias to the name of its task.
+	aliases map[string]string
+	// fileEnv holds the variables read from EnvFile.
+	fileEnv map[string]string
+}
+
+// ErrNoTaskFile is returned by FindTaskFile when there is no task file.
+var ErrNoTaskFile = fmt.Errorf("no %s found in current directory or any parent", DefaultFileName)
+
+// FindTaskFile walks from dir up to the filesystem root looking for a file
+// called DefaultFileName and returns its path.
+func FindTaskFile(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, DefaultFileName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ErrNoTaskFile
+		}
+		dir = parent
+	}
+}
+
+// Files returns the task file and every file it includes, directly or
+// not, in load order.
+func (ts *TaskSet) Files() []string {
+	return slices.Clone(ts.files)
+}
+
+// LoadTasks reads and parses the task file at path, merging in the tasks of
+// every file it includes.
+func LoadTasks(path string) (*TaskSet, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	ts, err := parseTaskFile(abs)
+	if err != nil {
+		return nil, err
+	}
+	ts.files = []string{abs}
+	if err := ts.merge(ts, []string{abs}); err != nil {
+		return nil, err
+	}
+	if err := ts.expandMatrices(); err != nil {
+		return nil, err
+	}
+	if err := ts.indexAliases(); err != nil {
+		return nil, err
+	}
+	if err := ts.checkDeps(); err != nil {
+		return nil, err
+	}
+	if err := ts.renderVars(); err != nil {
+		return nil, err
+	}
+	if err := ts.loadEnvFiles(); err != nil {
+		return nil, err
+	}
+	logger.Debug("loaded task file", "path", abs, "files", len(ts.files), "tasks", len(ts.Tasks))
+	return ts, nil
+}
+
+// parseTaskFile parses a single file without following its includes.
+func parseTaskFile(path string) (*TaskSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &TaskSet{}
+	if err := yaml.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	f.Path = path
+	if f.Tasks == nil {
+		f.Tasks = map[string]*Task{}
+	}
+	for name, t := range f.Tasks {
+		if t == nil {
+			t = &Task{}
+			f.Tasks[name] = t
+		}
+		t.Name = name
+		t.source = path
+	}
+	return f, nil
+}
+
+// merge adds the tasks of f, which is ts itself for the root file, and then
+// recursively of every file f includes. Include paths are relative to the
+// including file. stack holds the chain of files currently being included
+// and is used to report circular includes.
+func (ts *TaskSet) merge(f *TaskSet, stack []string) error {
+	if f != ts {
+		for name, t := range f.Tasks {
+			if prev, ok := ts.Tasks[name]; ok {
+				return fmt.Errorf("task %q is defined in both %s and %s", name, prev.source, t.source)
+			}
+			ts.Tasks[name] = t
+		}
+	}
+	for _, inc := range f.Include {
+		path := inc
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(f.Path), path)
+		}
+		path = filepath.Clean(path)
+		for i, p := range stack {
+			if p == path {
+				return fmt.Errorf("circular include: %s", strings.Join(append(stack[i:], path), " -> "))
+			}
+		}
+		if slices.Contains(ts.files, path) {
+			continue
+		}
+		logger.Debug("including task file", "path", path, "from", f.Path)
+		child, err := parseTaskFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: include: %w", f.Path, err)
+		}
+		ts.files = append(ts.files, path)
+		if err := ts.merge(child, append(stack, path)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexAliases registers the aliases of every task and rewrites
+// dependencies given by alias to the task's name, so the rest of zr only
+// deals in names. An alias may not shadow a task or another alias.
+func (ts *TaskSet) indexAliases() error {
+	ts.aliases = map[string]string{}
+	for _, name := range ts.Names() {
+		for _, alias := range ts.Tasks[name].Aliases {
+			if _, ok := ts.Tasks[alias]; ok {
+				return fmt.Errorf("alias %q of task %q collides with task %q", alias, name, alias)
+			}
+			if prev, ok := ts.aliases[alias]; ok {
+				return fmt.Errorf("alias %q is defined for both task %q and task %q", alias, prev, name)
+			}
+			ts.aliases[alias] = name
+		}
+	}
+	for _, t := range ts.Tasks {
+		for i, dep := range t.Deps {
+			t.Deps[i] = ts.Resolve(dep)
+		}
+		for i, prev := range t.RunAfter {
+			t.RunAfter[i] = ts.Resolve(prev)
+		}
+	}
+	return nil
+}
+
+// checkDeps reports every dependency and run_after entry, across all
+// tasks, that doesn't name a task or alias, so a typo fails the load rather
+// than a run halfway through.
+func (ts *TaskSet) checkDeps() error {
+	var errs []error
+	for _, name := range ts.Names() {
+		for _, dep := range ts.Tasks[name].Deps {
+			if _, ok := ts.Tasks[dep]; !ok {
+				errs = append(errs, fmt.Errorf("task %q depends on unknown task %q", name, dep))
+			}
+		}
+		for _, prev := range ts.Tasks[name].RunAfter {
+			if _, ok := ts.Tasks[prev]; !ok {
+				errs = append(errs, fmt.Errorf("task %q runs after unknown task %q", name, prev))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Resolve returns the name of the task name refers to, which is name itself
+// unless it's an alias.
+func (ts *TaskSet) Resolve(name string) string {
+	if target, ok := ts.aliases[name]; ok {
+		return target
+	}
+	return name
+}
+
+// Aliases returns a copy of the map from every alias to its task's name.
+func (ts *TaskSet) Aliases() map[string]string {
+	return maps.Clone(ts.aliases)
+}
+
+// Dir returns the directory containing the task file. Task commands run
+// there and relative paths are resolved against it.
+func (ts *TaskSet) Dir() string {
+	return filepath.Dir(ts.Path)
+}
+
+// workDir returns the directory t's command runs in: its cwd, with $VAR
+// references expanded against the task environment and resolved against
+// the task file's directory, or that directory itself.
+func (ts *TaskSet) workDir(t *Task) (string, error) {
+	if t.Cwd == "" {
+		return ts.Dir(), nil
+	}
+	env := environMap(ts.taskEnv(t))
+	dir := os.Expand(t.Cwd, func(name string) string { return env[name] })
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(ts.Dir(), dir)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", fmt.Errorf("task %q: working directory %s does not exist", t.Name, dir)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("task %q: working directory %s is not a directory", t.Name, dir)
+	}
+	return dir, nil
+}
+
+// DefaultTask returns the task to run when no command is given: the one
+// named by the top-level default key, else a task called "default".
+func (ts *TaskSet) DefaultTask() (string, bool) {
+	if ts.Default != "" {
+		return ts.Resolve(ts.Default), true
+	}
+	if _, ok := ts.Tasks["default"]; ok {
+		return "default", true
+	}
+	return "", false
+}
+
+// shellFor returns the interpreter t runs under: its own shell, else the
+// task file's, else the platform default.
+func (ts *TaskSet) shellFor(t *Task) string {
+	if t.Shell != "" {
+		return t.Shell
+	}
+	if ts.Shell != "" {
+		return ts.Shell
+	}
+	return defaultShell()
+}
+
+// Lookup returns the task called name or aliased as name.
+func (ts *TaskSet) Lookup(name string) (*Task, error) {
+	t, ok := ts.Tasks[ts.Resolve(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown task %q", name)
+	}
+	return t, nil
+}
+
+// Select returns the names of the tasks matching pattern, a shell-style
+// pattern as understood by path.Match such as test:*, in alphabetical
+// order. Aliases aren't matched. A pattern matching no task is an error.
+func (ts *TaskSet) Select(pattern string) ([]string, error) {
+	var names []string
+	for _, name := range ts.Names() {
+		ok, err := path.Match(pattern, name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		if ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no task matches %q", pattern)
+	}
+	return names, nil
+}
+
+// Names returns all task names in alphabetical order.
+func (ts *TaskSet) Names() []string {
+	names := make([]string, 0, len(ts.Tasks))
+	for name := range ts.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AppendArgs appends args, quoted for the task's shell, to the command of
+// the task called name. The task is replaced by a copy so cache keys and
+// plans reflect the command that actually runs.
+func (ts *TaskSet) AppendArgs(name string, args []string) error {
+	t, err := ts.Lookup(name)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	if len(t.Cmd) != 1 || strings.Contains(strings.TrimSpace(t.Cmd[0]), "\n") {
+		return fmt.Errorf("task %q is not a single command, so it can't take arguments", t.Name)
+	}
+	shell := ts.shellFor(t)
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(shell, arg)
+	}
+	clone := *t
+	clone.Cmd = CommandList{strings.TrimSpace(t.Cmd[0]) + " " + strings.Join(quoted, " ")}
+	ts.Tasks[t.Name] = &clone
+	return nil
+}
@@ -0,0 +1,145 @@
+package zr
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles creates files, keyed by slash-separated relative path, under a
+// fresh directory and returns it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+	return root
+}
+
+func TestLoadTaskFileIncludes(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"zr.yaml": `
+include: [ci/tasks.yaml]
+tasks:
+  build: {cmd: go build}
+`,
+		"ci/tasks.yaml": `
+include: [lint.yaml, ../shared.yaml]
+tasks:
+  test: {cmd: go test ./..., deps: [build]}
+`,
+		"ci/lint.yaml": `
+include: [../shared.yaml]
+tasks:
+  lint: {cmd: go vet ./...}
+`,
+		"shared.yaml": `
+tasks:
+  fmt: {cmd: go fmt ./...}
+`,
+	})
+	ts, err := LoadTasks(filepath.Join(root, "zr.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build", "fmt", "lint", "test"}, ts.Names())
+	assert.Equal(t, filepath.Join(root, "ci/tasks.yaml"), ts.Tasks["test"].source)
+	assert.Equal(t, []string{
+		filepath.Join(root, "zr.yaml"),
+		filepath.Join(root, "ci/tasks.yaml"),
+		filepath.Join(root, "ci/lint.yaml"),
+		filepath.Join(root, "shared.yaml"),
+	}, ts.Files())
+}
+
+func TestLoadTaskFileIncludeCollision(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"zr.yaml":   "include: [more.yaml]\ntasks:\n  build: {cmd: make}\n",
+		"more.yaml": "tasks:\n  build: {cmd: go build}\n",
+	})
+	_, err := LoadTasks(filepath.Join(root, "zr.yaml"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `task "build" is defined in both`)
+	assert.Contains(t, err.Error(), filepath.Join(root, "zr.yaml"))
+	assert.Contains(t, err.Error(), filepath.Join(root, "more.yaml"))
+}
+
+func TestTaskAliases(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  build: {cmd: go build, aliases: [b]}
+  test: {cmd: go test, deps: [b], aliases: [t]}
+`)
+	task, err := ts.Lookup("b")
+	require.NoError(t, err)
+	assert.Equal(t, "build", task.Name)
+	assert.Equal(t, []string{"build"}, ts.Tasks["test"].Deps, "deps given by alias are resolved")
+
+	levels, err := ts.Plan([]string{"t"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"build"}, {"test"}}, levelNames(levels))
+}
+
+func TestTaskAliasCollisions(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"task.yaml":  "tasks:\n  build: {aliases: [test]}\n  test: {}\n",
+		"alias.yaml": "tasks:\n  build: {aliases: [b]}\n  bench: {aliases: [b]}\n",
+	})
+	_, err := LoadTasks(filepath.Join(root, "task.yaml"))
+	assert.EqualError(t, err, `alias "test" of task "build" collides with task "test"`)
+	_, err = LoadTasks(filepath.Join(root, "alias.yaml"))
+	assert.EqualError(t, err, `alias "b" is defined for both task "bench" and task "build"`)
+}
+
+func TestLoadTasksUnknownDependencies(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"zr.yaml": `
+tasks:
+  build: {cmd: go build, deps: [compil, gen]}
+  gen: {cmd: go generate, aliases: [g]}
+  test: {cmd: go test, deps: [g, bulid], run_after: [g, lnt]}
+`,
+	})
+	_, err := LoadTasks(filepath.Join(root, "zr.yaml"))
+	assert.EqualError(t, err, `task "build" depends on unknown task "compil"`+"\n"+
+		`task "test" depends on unknown task "bulid"`+"\n"+
+		`task "test" runs after unknown task "lnt"`)
+}
+
+func TestLoadTaskFileCircularInclude(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"zr.yaml": "include: [a.yaml]\n",
+		"a.yaml":  "include: [b.yaml]\n",
+		"b.yaml":  "include: [a.yaml]\n",
+	})
+	_, err := LoadTasks(filepath.Join(root, "zr.yaml"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "circular include")
+	assert.Contains(t, err.Error(), "a.yaml -> "+filepath.Join(root, "b.yaml"))
+}
+
+func TestSelect(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  test:unit: {cmd: go test ./...}
+  test:integration: {cmd: go test -tags integration ./..., aliases: [test:int]}
+  test: {cmd: go test -short ./...}
+  lint: {cmd: go vet ./...}
+`)
+	names, err := ts.Select("test:*")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"test:integration", "test:unit"}, names)
+
+	names, err = ts.Select("[lt]*")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"lint", "test", "test:integration", "test:unit"}, names)
+
+	_, err = ts.Select("deploy*")
+	assert.EqualError(t, err, `no task matches "deploy*"`)
+	_, err = ts.Select("test[")
+	assert.EqualError(t, err, `invalid pattern "test[": syntax error in pattern`)
+}
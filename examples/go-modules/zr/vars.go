@@ -0,0 +1,82 @@
+package zr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// renderVars expands the {{ .vars.name }} templates in commands, hooks,
+// cwd, env values, inputs and outputs once at load time, so everything
+// downstream, including cache keys, sees the final strings. Tasks expanded
+// from a matrix can also use {{ .matrix.name }}. Referencing a variable
+// that isn't defined is an error. A literal {{ is written {{ "{{" }}.
+func (ts *TaskSet) renderVars() error {
+	if err := renderEnv(ts.Env, map[string]any{"vars": ts.Vars}); err != nil {
+		return err
+	}
+	for _, name := range ts.Names() {
+		t := ts.Tasks[name]
+		data := map[string]any{"vars": ts.Vars, "matrix": t.matrixVars}
+		lists := []struct {
+			field   string
+			strings []string
+		}{
+			{"before", t.Before}, {"cmd", t.Cmd}, {"after", t.After},
+			{"inputs", t.Inputs}, {"outputs", t.Outputs},
+		}
+		for _, l := range lists {
+			for i, s := range l.strings {
+				rendered, err := render(fmt.Sprintf("%s %d", l.field, i+1), s, data)
+				if err != nil {
+					return fmt.Errorf("task %q: %w", name, err)
+				}
+				l.strings[i] = rendered
+			}
+		}
+		cwd, err := render("cwd", t.Cwd, data)
+		if err != nil {
+			return fmt.Errorf("task %q: %w", name, err)
+		}
+		t.Cwd = cwd
+		if err := renderEnv(t.Env, data); err != nil {
+			return fmt.Errorf("task %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// renderEnv renders the values of env in place, in key order.
+func renderEnv(env map[string]string, data any) error {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v, err := render("env "+k, env[k], data)
+		if err != nil {
+			return err
+		}
+		env[k] = v
+	}
+	return nil
+}
+
+// render executes s as a template named after the field it came from.
+// Strings without {{ are returned as they are.
+func render(field, s string, data any) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	tmpl, err := template.New(field).Option("missingkey=error").Parse(s)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
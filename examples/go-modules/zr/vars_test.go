@@ -0,0 +1,55 @@
+package zr
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderVars(t *testing.T) {
+	ts := writeTaskFile(t, `
+vars:
+  version: "1.2.3"
+  out: bin
+env:
+  VERSION: "{{ .vars.version }}"
+tasks:
+  build:
+    cmd:
+      - go build -ldflags "-X main.version={{ .vars.version }}" -o {{ .vars.out }}/app
+      - docker ps --format '{{ "{{" }}.ID}}'
+    cwd: "{{ .vars.out }}"
+    env: {OUT: "{{ .vars.out }}/app", PLAIN: "$HOME"}
+`)
+	build := ts.Tasks["build"]
+	assert.Equal(t, CommandList{
+		`go build -ldflags "-X main.version=1.2.3" -o bin/app`,
+		`docker ps --format '{{.ID}}'`,
+	}, build.Cmd)
+	assert.Equal(t, "bin", build.Cwd)
+	assert.Equal(t, map[string]string{"OUT": "bin/app", "PLAIN": "$HOME"}, build.Env)
+	assert.Equal(t, "1.2.3", ts.Env["VERSION"])
+}
+
+func TestRenderVarsUndefined(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"zr.yaml": `
+vars: {version: "1.2.3"}
+tasks:
+  release: {cmd: "echo {{ .vars.verison }}"}
+`,
+		"novars.yaml": `
+tasks:
+  build: {cmd: "true", env: {OUT: "{{ .vars.out }}"}}
+`,
+	})
+	_, err := LoadTasks(filepath.Join(root, "zr.yaml"))
+	require.Error(t, err)
+	assert.Regexp(t, `^task "release": template: cmd 1:.*map has no entry for key "verison"$`, err.Error())
+
+	_, err = LoadTasks(filepath.Join(root, "novars.yaml"))
+	require.Error(t, err)
+	assert.Regexp(t, `^task "build": template: env OUT:.*map has no entry for key "out"$`, err.Error())
+}
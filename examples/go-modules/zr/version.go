@@ -0,0 +1,5 @@
+package zr
+
+// Version is the release of zr. It is also part of every cache key, so
+// upgrading invalidates cached results.
+const Version = "v1.0.0"
@@ -0,0 +1,165 @@
+package zr
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/example/gocli/internal/glob"
+)
+
+// Explanation says what a run would do with a task right now, and why.
+type Explanation struct {
+	Task *Task
+	// Skip is "up to date", "cached" or "not for" followed by the
+	// operating system if the task would be skipped, and empty if it would
+	// run.
+	Skip string
+	// Reasons are the findings behind the decision, in the order a run
+	// checks them.
+	Reasons []string
+	// DepsToRun are the task's dependencies, direct or not, that would run
+	// before it. They usually change its inputs, so a task that is skipped
+	// now may still run once they have.
+	DepsToRun []string
+}
+
+// Explain works out whether running name now would run it or skip it as up
+// to date or cached, using the same checks as a run, without running
+// anything.
+func (ts *TaskSet) Explain(name string) (*Explanation, error) {
+	plan, err := ts.Plan([]string{name})
+	if err != nil {
+		return nil, err
+	}
+	target := ts.Tasks[ts.Resolve(name)]
+	var x *Explanation
+	var depsToRun []string
+	for _, tasks := range plan {
+		for _, t := range tasks {
+			tx, err := ts.explainTask(t)
+			if err != nil {
+				return nil, err
+			}
+			if t == target {
+				x = tx
+			} else if tx.Skip == "" {
+				depsToRun = append(depsToRun, t.Name)
+			}
+		}
+	}
+	x.DepsToRun = depsToRun
+	return x, nil
+}
+
+// explainTask explains t on its own, ignoring its dependencies.
+func (ts *TaskSet) explainTask(t *Task) (*Explanation, error) {
+	x := &Explanation{Task: t}
+	reason := func(format string, args ...any) {
+		x.Reasons = append(x.Reasons, fmt.Sprintf(format, args...))
+	}
+	if !t.RunsOn(runtime.GOOS) {
+		reason("it is only for %s", strings.Join(t.OS, ", "))
+		x.Skip = "not for " + runtime.GOOS
+		return x, nil
+	}
+	if !t.Cacheable() {
+		switch {
+		case len(t.Cmd) == 0:
+			reason("it has no command and only groups its dependencies")
+		case len(t.Inputs) == 0 && len(t.Outputs) == 0:
+			reason("it declares no inputs or outputs, so it runs every time")
+		case len(t.Inputs) == 0:
+			reason("it declares no inputs, so it runs every time")
+		default:
+			reason("it declares no outputs, so it runs every time")
+		}
+		return x, nil
+	}
+
+	fresh, err := ts.explainFreshness(t, reason)
+	if err != nil {
+		return nil, err
+	}
+	if fresh {
+		x.Skip = "up to date"
+		return x, nil
+	}
+
+	key, err := ts.cacheKey(t)
+	if err != nil {
+		return nil, err
+	}
+	hit, err := ts.Cache().lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	switch _, outputsExist, err := existingOutputs(ts.Dir(), t); {
+	case err != nil:
+		return nil, err
+	case !hit:
+		reason("no successful run is cached for its cache key %.12s", key)
+	case !outputsExist:
+		reason("its cache key %.12s matches a successful run, but outputs are missing", key)
+	default:
+		reason("its cache key %.12s matches a successful run", key)
+		x.Skip = "cached"
+	}
+	return x, nil
+}
+
+// explainFreshness reports whether t is up to date, as upToDate does,
+// giving reason the missing outputs or the inputs newer than the oldest
+// output.
+func (ts *TaskSet) explainFreshness(t *Task, reason func(format string, args ...any)) (bool, error) {
+	root := ts.Dir()
+	outputs, err := glob.Expand(root, t.Outputs)
+	if err != nil {
+		return false, err
+	}
+	missing := false
+	for _, pattern := range t.Outputs {
+		if !strings.HasPrefix(pattern, "!") && !anyMatch(root, glob.Compile(pattern), outputs) {
+			reason("output %s doesn't exist", pattern)
+			missing = true
+		}
+	}
+	if missing {
+		return false, nil
+	}
+	oldest, oldestTime, err := mtimeBound(outputs, false)
+	if err != nil {
+		return false, err
+	}
+
+	inputs, err := glob.Expand(root, t.Inputs)
+	if err != nil {
+		return false, err
+	}
+	fresh := true
+	for _, in := range inputs {
+		info, err := os.Stat(in)
+		if err != nil {
+			return false, err
+		}
+		if !info.ModTime().Before(oldestTime) {
+			reason("input %s is newer than output %s", ts.rel(in), ts.rel(oldest))
+			fresh = false
+		}
+	}
+	if fresh {
+		reason("all %d outputs are newer than all %d inputs", len(outputs), len(inputs))
+	}
+	return fresh, nil
+}
+
+// rel returns path relative to the task file's directory, slash-separated,
+// for display.
+func (ts *TaskSet) rel(path string) string {
+	if rel, err := filepath.Rel(ts.Dir(), path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
@@ -0,0 +1,63 @@
+package zr
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	ts := writeTaskFile(t, `
+tasks:
+  gen: {cmd: touch gen.go}
+  build:
+    cmd: touch app
+    deps: [gen]
+    inputs: [main.go]
+    outputs: [app]
+  fmt: {cmd: go fmt}
+`)
+	root := ts.Dir()
+	now := time.Now()
+	touch(t, filepath.Join(root, "main.go"), now.Add(-time.Hour))
+
+	x, err := ts.Explain("build")
+	require.NoError(t, err)
+	assert.Empty(t, x.Skip)
+	assert.Equal(t, []string{"output app doesn't exist"}, x.Reasons[:1])
+	assert.Regexp(t, `^no successful run is cached for its cache key [0-9a-f]{12}$`, x.Reasons[1])
+	assert.Equal(t, []string{"gen"}, x.DepsToRun)
+
+	touch(t, filepath.Join(root, "app"), now.Add(-2*time.Hour))
+	x, err = ts.Explain("build")
+	require.NoError(t, err)
+	assert.Equal(t, "input main.go is newer than output app", x.Reasons[0])
+
+	_, err = ts.Run(context.Background(), []string{"build"}, RunOptions{Stdout: &bytes.Buffer{}, Only: true})
+	require.NoError(t, err)
+	touch(t, filepath.Join(root, "main.go"), now.Add(time.Hour))
+	x, err = ts.Explain("build")
+	require.NoError(t, err)
+	assert.Equal(t, "cached", x.Skip)
+	assert.Regexp(t, `^its cache key [0-9a-f]{12} matches a successful run$`, x.Reasons[1])
+
+	touch(t, filepath.Join(root, "main.go"), now.Add(-time.Hour))
+	touch(t, filepath.Join(root, "app"), now)
+	x, err = ts.Explain("build")
+	require.NoError(t, err)
+	assert.Equal(t, "up to date", x.Skip)
+	assert.Equal(t, []string{"all 1 outputs are newer than all 1 inputs"}, x.Reasons)
+
+	x, err = ts.Explain("fmt")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"it declares no inputs or outputs, so it runs every time"}, x.Reasons)
+	assert.Empty(t, x.DepsToRun)
+
+	_, err = ts.Explain("nope")
+	assert.EqualError(t, err, `unknown task "nope"`)
+}